@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWeatherCacheWarm(t *testing.T) {
+	cache := &WeatherCache{Store: map[string]CacheEntry{}, TTL: time.Minute}
+	cache.SetWithSource("read", "old", time.Second, &CacheSource{City: "Lagos", DataType: "current"})
+	cache.SetWithSource("unread", "old", time.Second, &CacheSource{City: "Abuja", DataType: "current"})
+	cache.SetWithSource("fresh", "old", time.Hour, &CacheSource{City: "Kano", DataType: "current"})
+	cache.SetWithTTL("nosource", "old", time.Second)
+	for _, key := range []string{"read", "fresh", "nosource"} {
+		cache.Get(key)
+	}
+
+	refreshed := []string{}
+	err := cache.Warm(context.Background(), time.Minute, func(ctx context.Context, source CacheSource) (any, time.Duration, error) {
+		refreshed = append(refreshed, source.City)
+		return "new", time.Hour, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(refreshed, ",") != "Lagos" {
+		t.Errorf("only entries read and about to expire should be refreshed, got %v", refreshed)
+	}
+	if data, _ := cache.Get("read"); data != "new" {
+		t.Errorf("expected the refreshed data, got %v", data)
+	}
+}
+
+func TestWeatherCacheSaveReplacesFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "weather_cache.json")
+	if err := os.WriteFile(path, []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cache := &WeatherCache{Store: map[string]CacheEntry{}, TTL: time.Minute, FilePath: path}
+	cache.SetWithTTL("Lagos", "sunny", time.Hour)
+	if err := cache.Save(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loaded := &WeatherCache{Store: map[string]CacheEntry{}, TTL: time.Minute, FilePath: path}
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("saved cache not loaded: %v", err)
+	}
+	if data, _ := loaded.Get("Lagos"); data != "sunny" {
+		t.Errorf("expected the saved entry, got %v", data)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Errorf("expected no temporary file left next to the cache, got %d files", len(files))
+	}
+}
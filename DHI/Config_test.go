@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lytup.json")
+	file := `{
+		"dhi": {"addr1": ":9080", "addr2": ":9443", "read_timeout": "1m"},
+		"daemons": {"DHI0": {"startup_grace": "5s"}}
+	}`
+	if err := os.WriteFile(path, []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LYTUP_CONFIG", path)
+	t.Setenv("LYTUP_DHI_ADDR2", ":10443")
+	t.Setenv("LYTUP_DHI_ALLOWED_RESPONSE_CODE", "200,400")
+
+	conf, err := LoadConfig([]string{"-dhi.addr2", ":11443"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if conf.DHI.Addr1 != ":9080" || time.Duration(conf.DHI.ReadTimeout) != time.Minute {
+		t.Errorf("file values not applied: %+v", conf.DHI)
+	}
+	if conf.DHI.Addr2 != ":11443" {
+		t.Errorf("flag should override environment and file, got %s", conf.DHI.Addr2)
+	}
+	if len(conf.DHI.AllowedResponseCode) != 2 || conf.DHI.AllowedResponseCode[1] != 400 {
+		t.Errorf("environment list not applied: %v", conf.DHI.AllowedResponseCode)
+	}
+	daemon := conf.Daemons["DHI0"]
+	if time.Duration(daemon.StartupGrace) != 5*time.Second || time.Duration(daemon.ShutdownGrace) != 30*time.Second {
+		t.Errorf("daemon settings should merge with defaults, got %+v", daemon)
+	}
+	if conf.DHI.TLSCert != DHI0_Addr2_Crt {
+		t.Errorf("unset values should keep their defaults, got %s", conf.DHI.TLSCert)
+	}
+}
+
+func TestLoadConfigFieldErrors(t *testing.T) {
+	t.Setenv("LYTUP_CONFIG", "")
+	_, err := LoadConfig([]string{
+		"-dhi.addr1", "8080", "-dhi.addr2", "", "-cache.ttl", "0s", "-daemons.DHI0.restart.when", "sometimes",
+		"-logging.time_zone", "Mars/Olympus_Mons", "-logging.sources.Weather", "verbose",
+	})
+
+	var confErr *ConfigError
+	if !errors.As(err, &confErr) {
+		t.Fatalf("expected ConfigError, got %v", err)
+	}
+	joined := strings.Join(confErr.Fields, "\n")
+	for _, field := range []string{"dhi.addr1:", "cache.ttl:", "daemons.DHI0.restart.when:", "logging.time_zone:", "logging.sources.Weather:"} {
+		if !strings.Contains(joined, field) {
+			t.Errorf("expected an error for %s, got:\n%s", field, joined)
+		}
+	}
+}
+
+func TestLoadConfigRejectsUnknownFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lytup.json")
+	if err := os.WriteFile(path, []byte(`{"dhi": {"adr1": ":80"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig([]string{"-config", path}); err == nil || !strings.Contains(err.Error(), "adr1") {
+		t.Errorf("expected unknown field error, got %v", err)
+	}
+}
@@ -7,7 +7,7 @@ var DHI0_Addr2 string = ":8443"
 var DHI0_Addr2_Key string = "tls.key"
 var DHI0_Addr2_Crt string = "tls.crt"
 var DHI0_RedirectHTTP bool = false
-var DHI0_RedirectDestination string = "https://localhost"
+var DHI0_RedirectDestination string = "https://localhost:8443"
 var DHI0_MaxHeaderSize int = 1 * 1024 * 1024
 var DHI0_ReadTimeout time.Duration = time.Minute * 5
 var DHI0_WrttTimeout time.Duration = time.Minute * 5
@@ -16,9 +16,38 @@ var DHI0_SPRegister []*DHI0_SP = []*DHI0_SP{
 	&DHI0_SP{
 		Code:    "weather",
 		Program: SPWeatherForecast,
+		Codes: []DHI0_Code{
+			{Code: 400, Meaning: "Seed invalid, or the city was not found"},
+			{Code: 502, Meaning: "The geocoding or weather service could not be contacted"},
+			{Code: 504, Meaning: "The geocoding service did not answer in time"},
+		},
+		Schema: &DHI0_Schema{
+			Fields: map[string]DHI0_Field{
+				"city":       {Type: FieldString, Required: true, Pattern: `\S`, Description: "City name, geocoded"},
+				"start_date": {Type: FieldDate, Required: true},
+				"end_date":   {Type: FieldDate, Required: true},
+				"data_type":  {Type: FieldString, Enum: []string{"current", "hourly", "both"}, Description: "Defaults to both"},
+			},
+		},
 	},
 }
+var DHI0_DiscoverySrID string = "dhi.discovery" // SrID answered with the enabled service providers, their Seed schemas and codes
 var DNI0_AllowedResponseCode []int = []int{500, 400, 406, 200}
+// Outcome codes with their identifier and meaning. A service provider declares the ones it returns in DHI0_SP.Codes
+var DHI0_ResponseCodes []DHI0_Code = []DHI0_Code{
+	{Code: 400, Error: "bad_request", Meaning: "The request or its Seed is invalid"},
+	{Code: 401, Error: "unauthorized", Meaning: "Credentials missing or not accepted"},
+	{Code: 404, Error: "not_found", Meaning: "The requested resource does not exist"},
+	{Code: 406, Error: "not_acceptable", Meaning: "The request can't be answered in an acceptable form"},
+	{Code: 409, Error: "conflict", Meaning: "The request conflicts with the current state"},
+	{Code: 429, Error: "rate_limited", Meaning: "Too many requests, retry later"},
+	{Code: 500, Error: "internal_error", Meaning: "The request failed in this service"},
+	{Code: 502, Error: "upstream_failed", Meaning: "A service this one depends on failed or could not be contacted"},
+	{Code: 503, Error: "unavailable", Meaning: "The service is temporarily unavailable"},
+	{Code: 504, Error: "upstream_timeout", Meaning: "A service this one depends on did not answer in time"},
+}
+var DHI0_StatusMode string = StatusMapped // StatusLegacy sends every response as HTTP 200
+var DHI0_StatusMap map[string]int = map[string]int{} // HTTP status by outcome code, for codes not sent as themselves
 var DNI0_ResponseHeaders [][]string = [][]string{
 	[]string{"Content-Type", "application/json"},
 }
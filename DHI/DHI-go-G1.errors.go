@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+/* Error object of a failed response, sent as Error in the envelope.
+ * Code is the response's ExecutionOutcomeError; Fields holds one error per offending Seed field, whose Code is the service provider's
+ */
+type DHI0_Error struct {
+	Code       string       `json:"code"`
+	Message    string       `json:"message"`
+	Field      string       `json:"field,omitempty"`       // Seed field path, e.g. "dates.start" or "cities[2]"
+	RetryAfter int          `json:"retry_after,omitempty"` // seconds after which the request may succeed
+	Incident   string       `json:"incident,omitempty"`    // logged with the cause of a 500
+	Fields     []DHI0_Error `json:"fields,omitempty"`
+}
+
+/* Returns a failed outcome for a service provider: return DHI0_Fail(400, "Seed invalid", DHI0_FieldError(...), ...).
+ * message is the note and the error object's message
+ */
+func DHI0_Fail(code int, message string, fields ...DHI0_Error) (int, string, any) {
+	return code, message, &DHI0_Error{Message: message, Fields: fields}
+}
+
+/* Returns a failed outcome that may succeed once after has passed, sent with a Retry-After header too
+ */
+func DHI0_RetryLater(code int, message string, after time.Duration) (int, string, any) {
+	return code, message, &DHI0_Error{Message: message, RetryAfter: retrySeconds(after)}
+}
+
+// Retry-After is given in whole seconds, rounded up
+func retrySeconds(after time.Duration) int {
+	return int((after + time.Second - 1) / time.Second)
+}
+
+/* Error returned by a typed service provider (RegisterTyped): the outcome code and the error object sent for it
+ */
+type DHI0_Failure struct {
+	Code int
+	DHI0_Error
+}
+
+func (f *DHI0_Failure) Error() string {
+	return f.Message
+}
+
+/* Returns the error of a typed service provider failing with an outcome code, like DHI0_Fail
+ */
+func DHI0_Failed(code int, message string, fields ...DHI0_Error) error {
+	return &DHI0_Failure{Code: code, DHI0_Error: DHI0_Error{Message: message, Fields: fields}}
+}
+
+/* Returns the error of a typed service provider whose request may succeed once after has passed, like DHI0_RetryLater
+ */
+func DHI0_RetryAfter(code int, message string, after time.Duration) error {
+	return &DHI0_Failure{Code: code, DHI0_Error: DHI0_Error{Message: message, RetryAfter: retrySeconds(after)}}
+}
+
+/* Returns the error of one Seed field, for DHI0_Fail. code is a stable identifier such as "required" or "invalid_date"
+ */
+func DHI0_FieldError(field, code, message string) DHI0_Error {
+	return DHI0_Error{Code: code, Message: message, Field: field}
+}
+
+/* Returns a new opaque incident ID
+ */
+func DHI0_NewIncident() string {
+	xb05 := make([]byte, 8)
+	rand.Read(xb05)
+	return hex.EncodeToString(xb05)
+}
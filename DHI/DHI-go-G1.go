@@ -2,17 +2,24 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"os"
 	"regexp"
 	"runtime/debug"
 	"slices"
+	"strconv"
 	"sync"
 	"time"
+
+	"github.com/PreciousM01/Precious-Lytup-Backend/DHI/daemoncore"
 )
 
 // Interface manager structure
@@ -29,31 +36,54 @@ type DHI struct {
 	SPRegister           []*DHI0_SP
 	AllowedResponseCode []int
 	ResponseHeaders      [][]string
+	StatusMode           string      // StatusMapped or StatusLegacy
+	StatusMap            map[int]int // HTTP status by outcome code; codes not listed are sent as themselves
 	TLSCert              string
 	TLSKey               string
+	ConfigSource         func() (*DHI, error) // re-reads the configuration on reload (nil - reload not supported)
+	Listeners            map[string]net.Listener // pre-bound listeners by role ("http", "https") used instead of binding Addr1/Addr2; left open when DHI shuts down
 
 	//Runtime shared state
 	Servers      []*http.Server
+	roles        map[*http.Server]string // "http" or "https"
 	ShutdownFlag bool        // shared across goroutines
-	Mutex        sync.Mutex // protects ShutdownFlag
+	inFlight     map[string]int // requests being served, by role; read through InFlight
+	Mutex        sync.Mutex // protects ShutdownFlag and inFlight
+	Certificate  *tls.Certificate // loaded from TLSCert and TLSKey
+	ConfMutex    sync.RWMutex     // protects the attributes swapped on reload (SPRegister, AllowedResponseCode, ResponseHeaders, StatusMode, StatusMap, TLSCert, TLSKey, Certificate)
 }
 
-// Create a new DHI instance, and initialize it.
-func NewDHI() *DHI{
+// Create a new DHI instance from the runtime configuration, and initialize it.
+// Service providers are taken from DHI0_SPRegister, limited to the codes listed in conf.DHI.Services
+func NewDHI(conf *Config) *DHI{
+	xb05 := []*DHI0_SP{}
+	for _, xc10 := range DHI0_SPRegister {
+		if slices.Contains(conf.DHI.Services, xc10.Code) {
+			xb05 = append(xb05, xc10)
+		}
+	}
+	// Keys were validated by Config.Validate
+	xb10 := map[int]int{}
+	for xc05, xc10 := range conf.DHI.StatusMap {
+		xc15, _ := strconv.Atoi(xc05)
+		xb10[xc15] = xc10
+	}
 	return &DHI{
-	Addr1:                DHI0_Addr1,
-	Addr2:                DHI0_Addr2,
-	RedirectHTTP:         DHI0_RedirectHTTP,
-	RedirectDestination:  DHI0_RedirectDestination,
-	MaxHeaderSize:        DHI0_MaxHeaderSize,
-	ReadTimeout:          DHI0_ReadTimeout,
-	WriteTimeout:         DHI0_WrttTimeout,
-	IdleTimeout:          DHI0_IdleTimeout,
-	SPRegister:           DHI0_SPRegister,
-	AllowedResponseCode: DNI0_AllowedResponseCode,
-	ResponseHeaders:      DNI0_ResponseHeaders,
-	TLSCert:              DHI0_Addr2_Crt, 
-	TLSKey:               DHI0_Addr2_Key,
+	Addr1:                conf.DHI.Addr1,
+	Addr2:                conf.DHI.Addr2,
+	RedirectHTTP:         conf.DHI.RedirectHTTP,
+	RedirectDestination:  conf.DHI.RedirectDestination,
+	MaxHeaderSize:        conf.DHI.MaxHeaderSize,
+	ReadTimeout:          time.Duration(conf.DHI.ReadTimeout),
+	WriteTimeout:         time.Duration(conf.DHI.WriteTimeout),
+	IdleTimeout:          time.Duration(conf.DHI.IdleTimeout),
+	SPRegister:           xb05,
+	AllowedResponseCode: conf.DHI.AllowedResponseCode,
+	ResponseHeaders:      conf.DHI.ResponseHeaders,
+	StatusMode:           conf.DHI.StatusMode,
+	StatusMap:            xb10,
+	TLSCert:              conf.DHI.TLSCert, 
+	TLSKey:               conf.DHI.TLSKey,
 
 	Servers:      []*http.Server{},
 	ShutdownFlag: false,   
@@ -63,156 +93,300 @@ func NewDHI() *DHI{
 }
 
 /* Start the DHI interface
- * Takes Clap and Flap channels as input
+ * Takes the daemon context, Clap and Flap channels as input. The interface shuts down once ctx is done or a shutdown command is received
  * Returns an error if any
 */
-func (d *DHI) DHIStart (Clap <-chan map[string]string, Flap chan<- map[string]string) (E error) {
+func (d *DHI) DHIStart(ctx context.Context, Clap <-chan daemoncore.DaemonCommand, Flap chan<- daemoncore.DaemonMessage) (E error) {
 	/***1***/
+	// A DHI can be started again after it was shut down (restart policy, control socket)
+	d.Mutex.Lock()
+	d.ShutdownFlag = false
+	d.Mutex.Unlock()
 	if err := d.DHI1ValidateCreateServers(Flap); err != nil {
 		return err
 	}
 
 	/***2***/
-	xb05 := 0
+	// Requests outlive the daemon context so in-flight requests can drain; they are cancelled once the shutdown deadline passes
+	xb01, xb02 := context.WithCancel(context.WithoutCancel(ctx))
+	defer xb02()
+	xb05 := make(chan error, len(d.Servers))
 	for _, xc10 := range d.Servers {
 		xc10.MaxHeaderBytes = d.MaxHeaderSize
 		xc10.ReadHeaderTimeout = d.ReadTimeout
+		xc10.WriteTimeout = d.WriteTimeout
+		xc10.IdleTimeout = d.IdleTimeout
+		xc10.BaseContext = func(net.Listener) context.Context { return xb01 }
+		xc10.TLSConfig = &tls.Config{GetCertificate: d.DHI1GetCertificate}
 		xc15 := bytes.NewBuffer([]byte{})
 		xc10.ErrorLog = log.New(xc15, "", log.Lshortfile)
-		if xc10.Addr == DHI0_Addr1 {
-			d.DHI1StartServer(xc10, `HTTP`, &xb05, &E)
+		if d.roles[xc10] == "http" {
+			d.DHI1StartServer(xc10, `HTTP`, d.Listeners["http"], xb05)
 		} else {
-			d.DHI1StartServer(xc10, `HTTPS`, &xb05, &E)
+			d.DHI1StartServer(xc10, `HTTPS`, d.Listeners["https"], xb05)
 		}
 	}
 
 	/***3***/
-	return d.DHI1_WaitForShutdown(Clap, &xb05, &E)
+	return d.DHI1_WaitForShutdown(ctx, Clap, Flap, xb05, xb02)
 }
 
 /* Create and configure servers.
  * Takes Flap channel as input
- * Returns an error if any
+ * Returns an error if the configuration is not valid
 */
-func (d *DHI) DHI1ValidateCreateServers(Flap chan<- map[string]string) (E error) {
-	xb01 := map[string]string{}
-
-	// Server 1
-	if d.Addr1 != "" {
-		xc05 := &http.Server{Addr: d.Addr1, Handler: d}
-		d.Servers = append(d.Servers, xc05)
-	}
-
-	// Server 2
-	if d.Addr2 != "" {
-		xc05 := &http.Server{Addr: d.Addr2, Handler: d}
-		d.Servers = append(d.Servers, xc05)
+func (d *DHI) DHI1ValidateCreateServers(Flap chan<- daemoncore.DaemonMessage) (E error) {
+	xb01 := daemoncore.StartupResult{}
+	d.Servers = nil
+	d.roles = map[*http.Server]string{}
+
+	// Server 1 and 2; a pre-bound listener takes the place of the address
+	for _, xc05 := range [][2]string{{"http", d.Addr1}, {"https", d.Addr2}} {
+		xc10 := d.Listeners[xc05[0]]
+		if xc05[1] == "" && xc10 == nil {
+			continue
+		}
+		xc15 := &http.Server{Addr: xc05[1], Handler: d}
+		if xc10 != nil {
+			xc15.Addr = xc10.Addr().String()
+		}
+		d.Servers = append(d.Servers, xc15)
+		d.roles[xc15] = xc05[0]
 	}
 
 	// No servers configured
 	if len(d.Servers) < 1 {
-		xb01["StartupCode"] = "500"
-		xb01["StartupNote"] = fmt.Sprintf(`HTTP and HTTPS addresses not configured`)
+		xb01.Code = 500
+		xb01.Note = fmt.Sprintf(`HTTP and HTTPS addresses not configured`)
 		Flap <- xb01
-		return
+		return errors.New(xb01.Note)
+	}
+
+	// TLS Certificate Check
+	if d.Addr2 != "" || d.Listeners["https"] != nil {
+		xc05, xc10 := tls.LoadX509KeyPair(d.TLSCert, d.TLSKey)
+		if xc10 != nil {
+			xb01.Code = 500
+			xb01.Note = fmt.Sprintf(`TLS certificate not loaded [%s]`, xc10.Error())
+			Flap <- xb01
+			return errors.New(xb01.Note)
+		}
+		d.Certificate = &xc05
 	}
 
 	// Redirect Config Check
 	if d.RedirectHTTP &&
 		regexp.MustCompile(`^https\:\/\/.+$`).MatchString(d.RedirectDestination) ==
 			false {
-		xb01["StartupCode"] = "500"
-		xb01["StartupNote"] = fmt.Sprintf(
+		xb01.Code = 500
+		xb01.Note = fmt.Sprintf(
 			`Conf parameter DHI0_RedirectDestination not valid`,
 		)
 		Flap <- xb01
-		return
+		return errors.New(xb01.Note)
 	}
 
 	// Successful configuration (*)
-	xb01["StartupCode"] = "200"
-	xb01["StartupNote"] = fmt.Sprintf(`OK`)
+	xb01.Code = 200
+	xb01.Note = fmt.Sprintf(`OK`)
 	Flap <- xb01
 	return
 }
 
 /* Starts servers and establish communication channel
- * Takes server, label, serverCount and E as input
+ * Takes server, label, the pre-bound listener to serve on (nil - bind srv.Addr) and the channel on which the server reports how it stopped (nil - closed on request)
  */
-func (d *DHI) DHI1StartServer(srv *http.Server, label string, serverCount *int, E *error) {
+func (d *DHI) DHI1StartServer(srv *http.Server, label string, listener net.Listener, done chan<- error) {
 
 	go func() {
-		// Update Server count
-		defer func() {
-			d.Mutex.Lock()
-			*serverCount = *serverCount + 1
-			d.Mutex.Unlock()
-		}()
-
 		time.Sleep(time.Millisecond * 100)
 
 		xb05 := fmt.Sprintf(
 			`%s interface listener started on %s`, label, srv.Addr)
 
-		Output_Logg("OUT", "DHI1", xb05)
+		daemoncore.Output_Logg("OUT", "DHI1", xb05)
 
 		// Starting Server
 		var xc05 error
-		if label == "HTTPS" {
-			xc05 = srv.ListenAndServeTLS(d.TLSCert, d.TLSKey)
-		} else {
+		var xc10 net.Listener
+		if listener != nil {
+			xc10, xc05 = DHI1RunListener(listener)
+		}
+		switch {
+		case xc05 != nil:
+		case label == "HTTPS" && xc10 != nil:
+			// Certificate is served by DHI1GetCertificate so it can be swapped on reload
+			xc05 = srv.ServeTLS(xc10, "", "")
+		case label == "HTTPS":
+			xc05 = srv.ListenAndServeTLS("", "")
+		case xc10 != nil:
+			xc05 = srv.Serve(xc10)
+		default:
 			xc05 = srv.ListenAndServe()
 		}
 
 		// Error Handling
 		d.Mutex.Lock()
+		defer d.Mutex.Unlock()
 		if xc05 != nil && !(d.ShutdownFlag) {
-			*E = errors.New(fmt.Sprintf(
+			done <- errors.New(fmt.Sprintf(
 				`%s interface listener unexpectedly shutdown [%s]`, label, xc05.Error(),
 			))
+			return
 		}
-		d.Mutex.Unlock()
+		done <- nil
 	}()
 }
 
-/* Keeps the interface running until it is told to stop or a failure occurs. Closes servers when shutddown is requested.
- * Takes Clap, serverCount and E as input
+/* Returns a listener on the same socket as a pre-bound listener, for a single run of DHI.
+ * Shutting the run down closes only the returned listener, so the socket stays open for the next run (restart, control socket start)
+ * Listeners that can't be duplicated are returned as they are and are closed with the run
+ */
+func DHI1RunListener(listener net.Listener) (net.Listener, error) {
+	xb05, xb10 := listener.(interface{ File() (*os.File, error) })
+	if !xb10 {
+		return listener, nil
+	}
+	xb15, xb20 := xb05.File()
+	if xb20 != nil {
+		return nil, xb20
+	}
+	defer xb15.Close()
+	return net.FileListener(xb15)
+}
+
+/* Keeps the interface running until it is told to stop or a failure occurs.
+ * Drains in-flight requests when shutdown is requested; requests still running at the shutdown deadline are cancelled and their servers closed.
+ * Takes the daemon context, Clap and Flap, the servers' done channel and the function cancelling in-flight requests as input
  * Returns an error if any, else returns nil
  */
-func (d *DHI) DHI1_WaitForShutdown(Clap <-chan map[string]string, serverCount *int, E *error) error {
+func (d *DHI) DHI1_WaitForShutdown(ctx context.Context, Clap <-chan daemoncore.DaemonCommand, Flap chan<- daemoncore.DaemonMessage, done <-chan error, cancelRequests context.CancelFunc) error {
 
-	for {
+	/***1***/
+	var xb05 error
+	xb10 := 0
+	xb12 := time.NewTicker(daemoncore.HeartbeatInterval)
+	defer xb12.Stop()
+	for xb15 := false; xb15 == false; {
 		select {
 
-		//Shutdown command received
-		case <-Clap:
-			d.Mutex.Lock()
-			d.ShutdownFlag = true
-			d.Mutex.Unlock()
+		// Still responsive; a heartbeat the manager didn't take yet is as good
+		case <-xb12.C:
+			select {
+			case Flap <- daemoncore.Heartbeat{}:
+			default:
+			}
 
-			for _, srv := range d.Servers {
-				srv.Close()
+		//Command received
+		case xc05 := <-Clap:
+			switch xc05.Kind {
+			case daemoncore.CommandShutdown:
+				xb15 = true
+			case daemoncore.CommandReload:
+				Flap <- d.DHI1Reload()
+			case daemoncore.CommandStatus:
+				Flap <- daemoncore.CommandResult{Command: xc05.Kind, Code: 200, Note: fmt.Sprintf(
+					`%d interface listener(s) running`, len(d.Servers)-xb10,
+				)}
+			default:
+				Flap <- daemoncore.CommandResult{Command: xc05.Kind, Code: 501, Note: fmt.Sprintf(
+					`Command %s not supported`, xc05.Kind,
+				)}
 			}
 
-		default:
+		case <-ctx.Done():
+			xb15 = true
 
-			d.Mutex.Lock()
-			err := *E
-			done := (*serverCount == len(d.Servers))
-			d.Mutex.Unlock()
+		// A listener stopped on its own
+		case xc05 := <-done:
+			xb10++
+			xb05 = xc05
+			xb15 = true
+		}
+	}
 
-			if err != nil {
-				return err
-			}
+	/***2***/
+	d.Mutex.Lock()
+	d.ShutdownFlag = true
+	d.Mutex.Unlock()
 
-			// If all servers are finished
-			if done {
-				return nil
-			}
+	xb20, xb25 := daemoncore.DaemonShutdownContext(ctx)
+	defer xb25()
+	go func() {
+		<-xb20.Done()
+		cancelRequests()
+	}()
+	for _, srv := range d.Servers {
+		if err := srv.Shutdown(xb20); err != nil {
+			srv.Close()
 		}
+	}
 
-		time.Sleep(time.Millisecond * 100)
+	/***3***/
+	for ; xb10 < len(d.Servers); xb10++ {
+		if xc05 := <-done; xc05 != nil && xb05 == nil {
+			xb05 = xc05
+		}
 	}
+	return xb05
+}
+
+/* Re-reads the configuration through ConfigSource and swaps in the TLS certificate, response headers, allowed response codes and service provider register.
+ * Listeners and in-flight requests are left untouched; addresses and timeouts only change on restart.
+ * Returns the reply to the reload command
+ */
+func (d *DHI) DHI1Reload() daemoncore.CommandResult {
+	xb05 := daemoncore.CommandResult{Command: daemoncore.CommandReload, Code: 500}
+	if d.ConfigSource == nil {
+		xb05.Code = 501
+		xb05.Note = `No configuration source to reload from`
+		return xb05
+	}
+
+	/***1***/
+	xb10, xb15 := d.ConfigSource()
+	if xb15 != nil {
+		xb05.Note = fmt.Sprintf(`Configuration not loaded [%s]`, xb15.Error())
+		return xb05
+	}
+	var xb20 *tls.Certificate
+	if d.Addr2 != "" || d.Listeners["https"] != nil {
+		xc05, xc10 := tls.LoadX509KeyPair(xb10.TLSCert, xb10.TLSKey)
+		if xc10 != nil {
+			xb05.Note = fmt.Sprintf(`TLS certificate not loaded [%s]`, xc10.Error())
+			return xb05
+		}
+		xb20 = &xc05
+	}
+
+	/***2***/
+	d.ConfMutex.Lock()
+	d.SPRegister = xb10.SPRegister
+	d.AllowedResponseCode = xb10.AllowedResponseCode
+	d.ResponseHeaders = xb10.ResponseHeaders
+	d.StatusMode = xb10.StatusMode
+	d.StatusMap = xb10.StatusMap
+	d.TLSCert = xb10.TLSCert
+	d.TLSKey = xb10.TLSKey
+	if xb20 != nil {
+		d.Certificate = xb20
+	}
+	d.ConfMutex.Unlock()
+
+	xb05.Code = 200
+	xb05.Note = fmt.Sprintf(`%d service provider(s) registered`, len(xb10.SPRegister))
+	return xb05
+}
+
+/* Serves the current TLS certificate to the HTTPS listener
+ */
+func (d *DHI) DHI1GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	d.ConfMutex.RLock()
+	defer d.ConfMutex.RUnlock()
+	if d.Certificate == nil {
+		return nil, errors.New(`no TLS certificate loaded`)
+	}
+	return d.Certificate, nil
 }
 
 /* Http request handler for the interface servers (Panic manager). 
@@ -220,12 +394,34 @@ func (d *DHI) DHI1_WaitForShutdown(Clap <-chan map[string]string, serverCount *i
  */
 func (d *DHI) ServeHTTP(R http.ResponseWriter, r *http.Request) {
 	/***1***/
+	xb00 := "http"
+	if r.TLS != nil {
+		xb00 = "https"
+	}
+	d.Mutex.Lock()
+	if d.inFlight == nil {
+		d.inFlight = map[string]int{}
+	}
+	d.inFlight[xb00]++
+	d.Mutex.Unlock()
+	defer func() {
+		d.Mutex.Lock()
+		d.inFlight[xb00]--
+		d.Mutex.Unlock()
+	}()
 	if r.TLS == nil && d.RedirectHTTP {
 		http.Redirect(R, r, d.RedirectDestination, http.StatusTemporaryRedirect)
+		return
 	}
 	/***2***/
+	d.ConfMutex.RLock()
+	xb01, xb02 := d.AllowedResponseCode, d.ResponseHeaders
+	xb03, xb04 := d.StatusMode, d.StatusMap
+	d.ConfMutex.RUnlock()
 	xb05 := map[string]any{}
 	xb05["ExecutionOutcomeCode"] = 500
+	var xb07 *DHI0_SP // service provider of the request, whose declared codes are allowed too
+	xb08 := ""        // error identifier replacing the registered one
 	defer func() {
 		/***1***/
 		xc01 := recover()
@@ -237,28 +433,53 @@ func (d *DHI) ServeHTTP(R http.ResponseWriter, r *http.Request) {
 		}
 		/***2***/
 		xc05 := xb05["ExecutionOutcomeCode"].(int)
-		if slices.Contains(d.AllowedResponseCode, xc05) == false && xc05 != 500 {
+		_, xc07 := xb07.Declared(xc05)
+		if slices.Contains(xb01, xc05) == false && xc07 == false && xc05 != 500 {
 			xb05["ExecutionOutcomeCode"] = 500
 			xb05["ExecutionOutcomeNote"] = fmt.Sprintf(
 				`Unexpected response code %d`, xc05,
 			)
+			xb08 = "undeclared_outcome_code"
+		}
+		if xb08 == "" {
+			xb08 = xb07.ErrorID(xb05["ExecutionOutcomeCode"].(int))
+		}
+		if xb08 != "" {
+			xb05["ExecutionOutcomeError"] = xb08
 		}
 		/***3***/
-		if xb05["ExecutionOutcomeCode"].(int) == 500 {
-			xd05, xd10 := xb05["ExecutionOutcomeNote"].(string)
-			if xd10 == false {
-				xd05 = "Execution Outcome Note not a string"
+		// A failure is described by the error object. The note of a 500 is only logged, under an incident ID the client can quote
+		xc15 := xb05["ExecutionOutcomeCode"].(int)
+		if xc15 >= 300 {
+			xd01, _ := xb05["ExecutionOutcomeNote"].(string)
+			xd05 := &DHI0_Error{Message: xd01}
+			if xe05, xe10 := xb05["Yield"].(*DHI0_Error); xe10 {
+				*xd05 = *xe05
+				delete(xb05, "Yield")
+			}
+			if xc15 == 500 {
+				xe05, xe10 := xb05["ExecutionOutcomeNote"].(string)
+				if xe10 == false {
+					xe05 = "Execution Outcome Note not a string"
+				}
+				xd05 = &DHI0_Error{Message: DHI0_ResponseCode(500).Meaning, Incident: DHI0_NewIncident()}
+				daemoncore.Output_Logg("ERR", "DHI2", fmt.Sprintf(`Incident %s [%s]`, xd05.Incident, xe05))
+				delete(xb05, "ExecutionOutcomeNote")
+			}
+			xd05.Code = xb08
+			if xd05.RetryAfter > 0 {
+				R.Header().Set("Retry-After", strconv.Itoa(xd05.RetryAfter))
 			}
-			Output_Logg("ERR", "DHI2", xd05)
-			delete(xb05, "ExecutionOutcomeNote")
+			xb05["Error"] = xd05
 		}
 		/***4***/
-		for _, xd05 := range d.ResponseHeaders {
+		for _, xd05 := range xb02 {
 			R.Header().Set(xd05[0], xd05[1])
 		}
 		/***5***/
 		xc10, _ := json.MarshalIndent(xb05, "", "    ")
 		xc10 = append(xc10, '\n')
+		R.WriteHeader(HTTPStatus(xb05["ExecutionOutcomeCode"].(int), xb03, xb04))
 		R.Write(xc10)
 	}()
 	/***3***/
@@ -290,6 +511,7 @@ func (d *DHI) ServeHTTP(R http.ResponseWriter, r *http.Request) {
 		return
 	}
 	/***4***/
+	xb07 = d.ServiceProvider(xb25.SrID)
 	xb35, xb40, xb45 := d.Route(r, xb25, R)
 	xb05["ExecutionOutcomeCode"] = xb35
 	xb05["ExecutionOutcomeNote"] = xb40
@@ -298,6 +520,38 @@ func (d *DHI) ServeHTTP(R http.ResponseWriter, r *http.Request) {
 	}
 }
 
+/* HTTP status a response is sent with, see DHI.StatusMode
+ */
+const (
+	StatusMapped = "mapped" // the outcome code, or its entry in the status map
+	StatusLegacy = "legacy" // always 200, for clients that only read ExecutionOutcomeCode
+)
+
+/* Returns the HTTP status for an outcome code.
+ * Takes the outcome code, the status mode and the status map as input
+ */
+func HTTPStatus(code int, mode string, table map[int]int) int {
+	if mode == StatusLegacy {
+		return http.StatusOK
+	}
+	if xb05, xb10 := table[code]; xb10 {
+		return xb05
+	}
+	return code
+}
+
+/* Returns the number of requests being served, by role ("http", "https")
+ */
+func (d *DHI) InFlight() map[string]int {
+	d.Mutex.Lock()
+	defer d.Mutex.Unlock()
+	xb05 := map[string]int{"http": 0, "https": 0}
+	for xc05, xc10 := range d.inFlight {
+		xb05[xc05] = xc10
+	}
+	return xb05
+}
+
 /* Selects the correct service provider for a request and executes it (Router).
  * Takes as input the request, the service provider ID and the seed.
  * Returns the response code, note and yield
@@ -307,22 +561,41 @@ func (d *DHI) Route(r *http.Request, s *DHI0_Request, R http.ResponseWriter) (
 ) {
 	/***1***/
 	C = 500
-	var ServiceProvider *DHI0_SP
-	for _, xc10 := range d.SPRegister {
-		if s.SrID == xc10.Code {
-			ServiceProvider = xc10
-		}
+	if s.SrID == DHI0_DiscoverySrID {
+		return 200, `Services`, d.Discovery()
 	}
+	ServiceProvider := d.ServiceProvider(s.SrID)
 	if ServiceProvider == nil {
 		C = 400
 		N = fmt.Sprintf(`Service specified not supported`)
 		return
 	}
 	/***2***/
+	if ServiceProvider.Schema != nil {
+		if xc05 := ServiceProvider.Schema.Validate(s.Seed); len(xc05) > 0 {
+			return DHI0_Fail(400, `Seed invalid`, xc05...)
+		}
+	}
+	/***3***/
 	C, N, Y = ServiceProvider.Program(r, s.SrID, s.Seed)
 	return
 }
 
+/* Returns the enabled service provider with the code, or nil
+ */
+func (d *DHI) ServiceProvider(code string) *DHI0_SP {
+	d.ConfMutex.RLock()
+	xb05 := d.SPRegister
+	d.ConfMutex.RUnlock()
+	var xb10 *DHI0_SP
+	for _, xc10 := range xb05 {
+		if code == xc10.Code {
+			xb10 = xc10
+		}
+	}
+	return xb10
+}
+
 // ============================================================================================//
 // 12345678901234567890123456789012345678901234567890123456789012345678901234567890123456789012//
 // 12345678901234567890123456789012345678901234567890123456789012345678901234567890123456789012//
@@ -334,6 +607,57 @@ type DHI0_Request struct {
 type DHI0_SP struct {
 	Code    string
 	Program func(*http.Request, string, map[string]any) (int, string, any)
+	Codes   []DHI0_Code // outcome codes Program may return besides AllowedResponseCode; any other is sent as 500
+	Schema  *DHI0_Schema // validates the Seed before Program is called (nil - Program checks it)
+}
+
+// An outcome code and what it means. Error is the machine-readable identifier sent as ExecutionOutcomeError
+type DHI0_Code struct {
+	Code    int    `json:"code"`
+	Error   string `json:"error,omitempty"` // "" - the identifier registered for Code in DHI0_ResponseCodes
+	Meaning string `json:"meaning"`
+}
+
+/* Returns the service provider's declaration of an outcome code, with the registered identifier filled in.
+ * A nil service provider (none routed to) declares no code
+ */
+func (sp *DHI0_SP) Declared(code int) (DHI0_Code, bool) {
+	if sp == nil {
+		return DHI0_Code{}, false
+	}
+	for _, xc05 := range sp.Codes {
+		if xc05.Code == code {
+			if xc05.Error == "" {
+				xc05.Error = DHI0_ResponseCode(code).Error
+			}
+			return xc05, true
+		}
+	}
+	return DHI0_Code{}, false
+}
+
+/* Returns the machine-readable error identifier of an outcome code: the service provider's, else the registered one.
+ * Success codes (below 300) have none
+ */
+func (sp *DHI0_SP) ErrorID(code int) string {
+	if code < 300 {
+		return ""
+	}
+	if xb05, xb10 := sp.Declared(code); xb10 {
+		return xb05.Error
+	}
+	return DHI0_ResponseCode(code).Error
+}
+
+/* Returns the registered meaning of an outcome code from DHI0_ResponseCodes; unregistered codes get "error"
+ */
+func DHI0_ResponseCode(code int) DHI0_Code {
+	for _, xc05 := range DHI0_ResponseCodes {
+		if xc05.Code == code {
+			return xc05
+		}
+	}
+	return DHI0_Code{Code: code, Error: "error", Meaning: http.StatusText(code)}
 }
 
 
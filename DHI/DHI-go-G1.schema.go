@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PreciousM01/Precious-Lytup-Backend/DHI/daemoncore"
+)
+
+/* Seed schema of a service provider. DHI validates the Seed against it before calling Program,
+ * reporting every violation at once, and lists it in the service discovery (DHI0_DiscoverySrID)
+ */
+type DHI0_Schema struct {
+	Strict bool                  `json:"strict"` // Seed fields not in Fields are rejected
+	Fields map[string]DHI0_Field `json:"fields"`
+}
+
+// Seed field types
+const (
+	FieldString  = "string"
+	FieldNumber  = "number"
+	FieldInteger = "integer"
+	FieldBoolean = "boolean"
+	FieldDate    = "date" // a string in Format
+	FieldObject  = "object"
+	FieldArray   = "array"
+)
+
+/* One Seed field. Constraints that don't apply to Type are ignored
+ */
+type DHI0_Field struct {
+	Type        string                `json:"type"`
+	Required    bool                  `json:"required,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Enum        []string              `json:"enum,omitempty"`    // allowed strings
+	Pattern     string                `json:"pattern,omitempty"` // regular expression strings must match
+	Min         *float64              `json:"min,omitempty"`     // numbers, and the length of arrays
+	Max         *float64              `json:"max,omitempty"`
+	Format      string                `json:"format,omitempty"` // Go time layout of dates ("" - 2006-01-02)
+	Fields      map[string]DHI0_Field `json:"fields,omitempty"` // of objects; strict like the schema
+	Items       *DHI0_Field           `json:"items,omitempty"`  // of arrays
+}
+
+/* Returns a pointer to a bound, for DHI0_Field.Min and Max
+ */
+func DHI0_Bound(value float64) *float64 {
+	return &value
+}
+
+/* Returns an error if the schema can't be used: unknown types, patterns that don't compile, enums of fields that aren't strings
+ */
+func (schema *DHI0_Schema) Check() error {
+	xb05 := []string{}
+	var xb10 func(path string, field DHI0_Field)
+	xb10 = func(path string, field DHI0_Field) {
+		switch field.Type {
+		case FieldString, FieldNumber, FieldInteger, FieldBoolean, FieldDate, FieldObject, FieldArray:
+		default:
+			xb05 = append(xb05, fmt.Sprintf(`%s: unknown type %q`, path, field.Type))
+		}
+		if field.Pattern != "" {
+			if _, xc05 := compilePattern(field.Pattern); xc05 != nil {
+				xb05 = append(xb05, fmt.Sprintf(`%s: pattern invalid [%s]`, path, xc05.Error()))
+			}
+		}
+		if len(field.Enum) > 0 && field.Type != FieldString {
+			xb05 = append(xb05, fmt.Sprintf(`%s: enum only applies to strings`, path))
+		}
+		for _, xc05 := range sortedFields(field.Fields) {
+			xb10(path+"."+xc05, field.Fields[xc05])
+		}
+		if field.Items != nil {
+			xb10(path+"[]", *field.Items)
+		}
+	}
+	for _, xc05 := range sortedFields(schema.Fields) {
+		xb10(xc05, schema.Fields[xc05])
+	}
+	if len(xb05) > 0 {
+		return fmt.Errorf(`%s`, strings.Join(xb05, "; "))
+	}
+	return nil
+}
+
+/* Validates a Seed against the schema.
+ * Returns one field error per violation, in field order, with the codes required, unknown_field, type, enum, pattern, range and date_format
+ */
+func (schema *DHI0_Schema) Validate(seed map[string]any) []DHI0_Error {
+	xb05 := []DHI0_Error{}
+	validateObject("", seed, schema.Fields, schema.Strict, &xb05)
+	return xb05
+}
+
+func validateObject(path string, value map[string]any, fields map[string]DHI0_Field, strict bool, errs *[]DHI0_Error) {
+	for _, xc05 := range sortedFields(fields) {
+		xc10 := fields[xc05]
+		xc15, xc20 := value[xc05]
+		if xc20 == false || xc15 == nil {
+			if xc10.Required {
+				*errs = append(*errs, DHI0_FieldError(joinFieldPath(path, xc05), "required", "missing "+joinFieldPath(path, xc05)))
+			}
+			continue
+		}
+		validateField(joinFieldPath(path, xc05), xc15, xc10, strict, errs)
+	}
+	if strict {
+		xb05 := []string{}
+		for xc05 := range value {
+			if _, xc10 := fields[xc05]; xc10 == false {
+				xb05 = append(xb05, xc05)
+			}
+		}
+		sort.Strings(xb05)
+		for _, xc05 := range xb05 {
+			*errs = append(*errs, DHI0_FieldError(joinFieldPath(path, xc05), "unknown_field", "unknown field "+joinFieldPath(path, xc05)))
+		}
+	}
+}
+
+func validateField(path string, value any, field DHI0_Field, strict bool, errs *[]DHI0_Error) {
+	xb05 := func(code, format string, a ...any) {
+		*errs = append(*errs, DHI0_FieldError(path, code, fmt.Sprintf(path+": "+format, a...)))
+	}
+	xb10 := func(number float64) {
+		if (field.Min != nil && number < *field.Min) || (field.Max != nil && number > *field.Max) {
+			xb05("range", "%v is out of range%s", number, describeRange(field.Min, field.Max))
+		}
+	}
+	switch field.Type {
+	case FieldString, FieldDate:
+		xc05, xc10 := value.(string)
+		if xc10 == false {
+			xb05("type", "must be a %s", field.Type)
+			return
+		}
+		// Enums only apply to strings, as Check requires
+		if field.Type == FieldString && len(field.Enum) > 0 && slices.Contains(field.Enum, xc05) == false {
+			xb05("enum", "must be one of %s", strings.Join(field.Enum, ", "))
+		}
+		if field.Pattern != "" {
+			if xc15, xc20 := compilePattern(field.Pattern); xc20 == nil && xc15.MatchString(xc05) == false {
+				xb05("pattern", "must match %s", field.Pattern)
+			}
+		}
+		if field.Type == FieldDate {
+			xc15 := field.Format
+			if xc15 == "" {
+				xc15 = time.DateOnly
+			}
+			if _, xc20 := time.Parse(xc15, xc05); xc20 != nil {
+				xb05("date_format", "must be a date formatted as %s", xc15)
+			}
+		}
+	case FieldNumber, FieldInteger:
+		xc05, xc10 := value.(float64)
+		if xc10 == false || (field.Type == FieldInteger && xc05 != math.Trunc(xc05)) {
+			if field.Type == FieldInteger {
+				xb05("type", "must be an integer")
+			} else {
+				xb05("type", "must be a number")
+			}
+			return
+		}
+		xb10(xc05)
+	case FieldBoolean:
+		if _, xc10 := value.(bool); xc10 == false {
+			xb05("type", "must be a boolean")
+		}
+	case FieldObject:
+		xc05, xc10 := value.(map[string]any)
+		if xc10 == false {
+			xb05("type", "must be an object")
+			return
+		}
+		validateObject(path, xc05, field.Fields, strict, errs)
+	case FieldArray:
+		xc05, xc10 := value.([]any)
+		if xc10 == false {
+			xb05("type", "must be an array")
+			return
+		}
+		if (field.Min != nil && float64(len(xc05)) < *field.Min) || (field.Max != nil && float64(len(xc05)) > *field.Max) {
+			xb05("range", "%d items are out of range%s", len(xc05), describeRange(field.Min, field.Max))
+		}
+		if field.Items != nil {
+			for xd05, xd10 := range xc05 {
+				validateField(fmt.Sprintf(`%s[%d]`, path, xd05), xd10, *field.Items, strict, errs)
+			}
+		}
+	}
+}
+
+func joinFieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func describeRange(min, max *float64) string {
+	switch {
+	case min != nil && max != nil:
+		return fmt.Sprintf(` [%v, %v]`, *min, *max)
+	case min != nil:
+		return fmt.Sprintf(` [%v, ...]`, *min)
+	case max != nil:
+		return fmt.Sprintf(` [..., %v]`, *max)
+	}
+	return ""
+}
+
+func sortedFields(fields map[string]DHI0_Field) []string {
+	xb05 := make([]string, 0, len(fields))
+	for xc05 := range fields {
+		xb05 = append(xb05, xc05)
+	}
+	sort.Strings(xb05)
+	return xb05
+}
+
+// Patterns are compiled once, failures included
+var patterns sync.Map
+
+type compiledPattern struct {
+	regexp *regexp.Regexp
+	err    error
+}
+
+/* Returns the compiled pattern, or why it doesn't compile. A schema that wasn't checked (Check) may hold such a pattern:
+ * it is logged once and not applied, rather than failing every request
+ */
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if xb05, xb10 := patterns.Load(pattern); xb10 {
+		return xb05.(compiledPattern).regexp, xb05.(compiledPattern).err
+	}
+	xb05, xb10 := regexp.Compile(pattern)
+	if _, xb15 := patterns.LoadOrStore(pattern, compiledPattern{regexp: xb05, err: xb10}); xb15 == false && xb10 != nil {
+		daemoncore.Output_Logg("ERR", "DHI2", fmt.Sprintf(`Seed pattern %q not applied [%s]`, pattern, xb10.Error()))
+	}
+	return xb05, xb10
+}
+
+/* Describes the enabled service providers for the service discovery: their Seed schema and the outcome codes they may return
+ */
+type DHI0_ServiceDoc struct {
+	Code   string       `json:"code"`
+	Schema *DHI0_Schema `json:"schema,omitempty"`
+	Codes  []DHI0_Code  `json:"codes"`
+}
+
+/* Returns the service discovery: one entry per enabled service provider, with DHI's own codes (AllowedResponseCode) and the declared ones
+ */
+func (d *DHI) Discovery() []DHI0_ServiceDoc {
+	d.ConfMutex.RLock()
+	xb05, xb10 := d.SPRegister, d.AllowedResponseCode
+	d.ConfMutex.RUnlock()
+	xb15 := []DHI0_ServiceDoc{}
+	for _, xc05 := range xb05 {
+		xc10 := DHI0_ServiceDoc{Code: xc05.Code, Schema: xc05.Schema, Codes: []DHI0_Code{}}
+		xc15 := slices.Clone(xb10)
+		for _, xd05 := range xc05.Codes {
+			xc15 = append(xc15, xd05.Code)
+		}
+		slices.Sort(xc15)
+		for _, xd05 := range slices.Compact(xc15) {
+			xd10, xd15 := xc05.Declared(xd05)
+			if xd15 == false {
+				xd10 = DHI0_ResponseCode(xd05)
+			}
+			if xd10.Meaning == "" {
+				xd10.Meaning = DHI0_ResponseCode(xd05).Meaning
+			}
+			if xd05 < 300 {
+				xd10.Error = ""
+			}
+			xc10.Codes = append(xc10.Codes, xd10)
+		}
+		xb15 = append(xb15, xc10)
+	}
+	return xb15
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* Registers a typed service provider in DHI0_SPRegister and returns it. Call it from an init function, before the configuration is loaded.
+ * The Seed is validated against the schema of In (SchemaOf) and decoded into In; the Out returned is sent as the Yield.
+ * Errors become outcome codes: a *DHI0_Failure (DHI0_Failed, DHI0_RetryAfter) its Code, context.DeadlineExceeded 504, any other 500.
+ * codes are the outcome codes the program returns besides AllowedResponseCode, as DHI0_SP.Codes; 504 is always declared.
+ * Panics if In isn't a struct SchemaOf can describe
+ */
+func RegisterTyped[In, Out any](code string, program func(context.Context, In) (Out, error), codes ...DHI0_Code) *DHI0_SP {
+	xb05 := &DHI0_SP{Code: code, Codes: codes, Schema: SchemaOf[In](), Program: TypedProgram(program)}
+	if _, xb10 := xb05.Declared(504); xb10 == false {
+		xb05.Codes = append(xb05.Codes, DHI0_Code{Code: 504})
+	}
+	DHI0_SPRegister = append(DHI0_SPRegister, xb05)
+	return xb05
+}
+
+/* Adapts a typed program to the DHI0_SP.Program contract, see RegisterTyped.
+ * The Seed is expected to be validated already: a Seed that doesn't decode into In is still rejected with a 400
+ */
+func TypedProgram[In, Out any](program func(context.Context, In) (Out, error)) func(*http.Request, string, map[string]any) (int, string, any) {
+	return func(r *http.Request, srID string, seed map[string]any) (int, string, any) {
+		/***1***/
+		var xb05 In
+		xb10, _ := json.Marshal(seed)
+		if xc05 := json.Unmarshal(xb10, &xb05); xc05 != nil {
+			var xc10 *json.UnmarshalTypeError
+			if errors.As(xc05, &xc10) && xc10.Field != "" {
+				return DHI0_Fail(400, `Seed invalid`, DHI0_FieldError(xc10.Field, "type", fmt.Sprintf(`%s: must be a %s`, xc10.Field, xc10.Type)))
+			}
+			return DHI0_Fail(400, fmt.Sprintf(`Seed not decoded [%s]`, xc05.Error()))
+		}
+
+		/***2***/
+		xb15 := context.Background()
+		if r != nil {
+			xb15 = r.Context()
+		}
+		xb20, xb25 := program(xb15, xb05)
+
+		/***3***/
+		var xb30 *DHI0_Failure
+		switch {
+		case xb25 == nil:
+			return 200, `OK`, xb20
+		case errors.As(xb25, &xb30):
+			xc05 := xb30.DHI0_Error
+			return xb30.Code, xb30.Message, &xc05
+		case errors.Is(xb25, context.DeadlineExceeded):
+			return 504, xb25.Error(), nil
+		}
+		return 500, xb25.Error(), nil
+	}
+}
+
+/* Returns the strict Seed schema of a struct type, named by the json tags of its fields. Field constraints are set with tags:
+ * required:"true", description:"...", enum:"a,b,c", pattern:"...", min:"1", max:"16" and format:"2006-01-02", which makes a string a date.
+ * Pointers are described by what they point to, time.Time as an RFC 3339 date, nested structs as objects, []byte as a base64 string,
+ * other slices and arrays as arrays. Fields of embedded structs are promoted as by encoding/json.
+ * Panics for other types and for an embedded time.Time
+ */
+func SchemaOf[T any]() *DHI0_Schema {
+	xb05 := reflect.TypeFor[T]()
+	for xb05.Kind() == reflect.Pointer {
+		xb05 = xb05.Elem()
+	}
+	if xb05.Kind() != reflect.Struct {
+		panic(fmt.Sprintf(`SchemaOf: %s is not a struct`, xb05))
+	}
+	return &DHI0_Schema{Strict: true, Fields: schemaFields(xb05)}
+}
+
+func schemaFields(t reflect.Type) map[string]DHI0_Field {
+	xb05 := map[string]*schemaCandidate{}
+	collectSchemaFields(t, 0, xb05, map[reflect.Type]bool{})
+	xb10 := map[string]DHI0_Field{}
+	for xc05, xc10 := range xb05 {
+		if xc10.count == 1 {
+			xb10[xc05] = xc10.field
+		}
+	}
+	return xb10
+}
+
+// A field named so in a Seed, and how encoding/json ranks it against other fields of the same name
+type schemaCandidate struct {
+	field  DHI0_Field
+	depth  int  // embedding depth
+	tagged bool // named by a json tag
+	count  int  // fields of that rank; more than one are ignored, as by encoding/json
+}
+
+/* Adds the fields of t to found. Fields of embedded structs without a json name are promoted, as encoding/json does:
+ * a shallower field hides deeper ones of the same name, a tagged one untagged ones, and the ones left tied are ignored
+ */
+func collectSchemaFields(t reflect.Type, depth int, found map[string]*schemaCandidate, visited map[reflect.Type]bool) {
+	if visited[t] {
+		return
+	}
+	visited[t] = true
+	for xc05 := 0; xc05 < t.NumField(); xc05++ {
+		xc10 := t.Field(xc05)
+		xc15, _, _ := strings.Cut(xc10.Tag.Get("json"), ",")
+		if xc15 == "-" {
+			continue
+		}
+		if xc10.Anonymous {
+			xd05 := xc10.Type
+			if xd05.Kind() == reflect.Pointer {
+				xd05 = xd05.Elem()
+			}
+			if xd05 == reflect.TypeFor[time.Time]() {
+				panic(fmt.Sprintf(`SchemaOf: %s.%s: embedded time.Time not supported`, t, xc10.Name))
+			}
+			if xc15 == "" && xd05.Kind() == reflect.Struct {
+				collectSchemaFields(xd05, depth+1, found, visited)
+				continue
+			}
+		}
+		if xc10.IsExported() == false {
+			continue
+		}
+		xc20 := xc15 != ""
+		if xc15 == "" {
+			xc15 = xc10.Name
+		}
+		xc25 := found[xc15]
+		switch {
+		case xc25 == nil || depth < xc25.depth || (depth == xc25.depth && xc20 && xc25.tagged == false):
+			found[xc15] = &schemaCandidate{
+				field: schemaField(fmt.Sprintf(`%s.%s`, t, xc10.Name), xc10.Type, xc10.Tag), depth: depth, tagged: xc20, count: 1,
+			}
+		case depth == xc25.depth && xc20 == xc25.tagged:
+			xc25.count++
+		}
+	}
+}
+
+func schemaField(name string, t reflect.Type, tag reflect.StructTag) DHI0_Field {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	xb05 := DHI0_Field{
+		Required:    tag.Get("required") == "true",
+		Description: tag.Get("description"),
+		Pattern:     tag.Get("pattern"),
+		Format:      tag.Get("format"),
+	}
+	if xc05 := tag.Get("enum"); xc05 != "" {
+		xb05.Enum = strings.Split(xc05, ",")
+	}
+	for xc05, xc10 := range map[string]**float64{"min": &xb05.Min, "max": &xb05.Max} {
+		if xc15 := tag.Get(xc05); xc15 != "" {
+			xc20, xc25 := strconv.ParseFloat(xc15, 64)
+			if xc25 != nil {
+				panic(fmt.Sprintf(`SchemaOf: %s: %s:%q is not a number`, name, xc05, xc15))
+			}
+			*xc10 = DHI0_Bound(xc20)
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if t != reflect.TypeFor[time.Time]() {
+			xb05.Type = FieldObject
+			xb05.Fields = schemaFields(t)
+			break
+		}
+		// Decoded by encoding/json from RFC 3339
+		xb05.Type = FieldDate
+		xb05.Format = time.RFC3339
+	case reflect.String:
+		xb05.Type = FieldString
+		if xb05.Format != "" {
+			xb05.Type = FieldDate
+		}
+	case reflect.Bool:
+		xb05.Type = FieldBoolean
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		xb05.Type = FieldInteger
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		xb05.Type = FieldInteger
+		if xb05.Min == nil {
+			xb05.Min = DHI0_Bound(0)
+		}
+	case reflect.Float32, reflect.Float64:
+		xb05.Type = FieldNumber
+	case reflect.Slice, reflect.Array:
+		// encoding/json decodes []byte from base64
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			xb05.Type = FieldString
+			if xb05.Pattern == "" {
+				xb05.Pattern = `^[A-Za-z0-9+/]*={0,2}$`
+			}
+			if xb05.Description == "" {
+				xb05.Description = "base64"
+			}
+			break
+		}
+		xb05.Type = FieldArray
+		xc05 := schemaField(name+"[]", t.Elem(), "")
+		xb05.Items = &xc05
+	default:
+		panic(fmt.Sprintf(`SchemaOf: %s: type %s not supported`, name, t))
+	}
+	return xb05
+}
@@ -0,0 +1,513 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PreciousM01/Precious-Lytup-Backend/DHI/daemoncore"
+)
+
+// Starts DHI0 on a free local port, without HTTPS, after setup (if any) adjusted the DHI
+func startDHI(t *testing.T, setup func(d *DHI)) (*DHI, *daemoncore.Manager) {
+	t.Helper()
+	d := NewDHI(DefaultConfig())
+	d.Addr1 = "127.0.0.1:0"
+	d.Addr2 = ""
+	if setup != nil {
+		setup(d)
+	}
+	daemon := &daemoncore.Daemon{Name: "DHI0", ContextProgram: d.DHIStart, StartupGrace: time.Second, ShutdownGrace: time.Second}
+	manager := &daemoncore.Manager{Daemons: []*daemoncore.Daemon{daemon}}
+	if err := manager.DaemonStartUp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return d, manager
+}
+
+func TestDHIStartAndShutdown(t *testing.T) {
+	_, manager := startDHI(t, nil)
+	result, err := manager.DaemonCommand("DHI0", daemoncore.CommandStatus, time.Second)
+	if err != nil || result.Code != 200 {
+		t.Errorf("expected status reply, got %+v, %v", result, err)
+	}
+
+	// The execution outcome is consumed by the shutdown
+	if report := manager.DaemonShutDown(); len(report.Daemons) != 1 || report.Daemons[0].Result != daemoncore.ShutdownClean {
+		t.Errorf("expected DHI0 to shut down cleanly, got %+v", report)
+	}
+	if phase := manager.Daemons[0].Snapshot().Phase; phase != daemoncore.PhaseStopped {
+		t.Errorf("expected DHI0 to be stopped after shutdown, got %s", phase)
+	}
+}
+
+func TestDHIStartsAgainAfterStop(t *testing.T) {
+	_, manager := startDHI(t, nil)
+	daemon := manager.Daemons[0]
+	manager.DaemonStop(daemon)
+	if !manager.DaemonStart(daemon) {
+		t.Fatalf("DHI0 did not start again: %+v", daemon.Snapshot())
+	}
+	result, err := manager.DaemonCommand("DHI0", daemoncore.CommandStatus, time.Second)
+	if err != nil || result.Note != "1 interface listener(s) running" {
+		t.Errorf("expected one listener after the restart, got %+v, %v", result, err)
+	}
+	manager.DaemonShutDown()
+}
+
+func TestDHIReloadSwapsConfiguration(t *testing.T) {
+	d, manager := startDHI(t, func(d *DHI) {
+		d.ConfigSource = func() (*DHI, error) {
+			reloaded := NewDHI(DefaultConfig())
+			reloaded.SPRegister = []*DHI0_SP{{Code: "sp01", Program: SP01}}
+			reloaded.AllowedResponseCode = []int{200, 400, 404, 500}
+			return reloaded, nil
+		}
+	})
+	defer manager.DaemonShutDown()
+
+	result, err := manager.DaemonCommand("DHI0", daemoncore.CommandReload, time.Second)
+	if err != nil || result.Code != 200 {
+		t.Fatalf("expected reload to succeed, got %+v, %v", result, err)
+	}
+	code, _, _ := d.Route(nil, &DHI0_Request{SrID: "sp01"}, nil)
+	if code != 200 {
+		t.Errorf("expected reloaded service provider to be routed, got %d", code)
+	}
+	code, _, _ = d.Route(nil, &DHI0_Request{SrID: "weather"}, nil)
+	if code != 400 {
+		t.Errorf("expected removed service provider to be rejected, got %d", code)
+	}
+}
+
+func TestDHIServerTimeouts(t *testing.T) {
+	d, manager := startDHI(t, func(d *DHI) {
+		d.ReadTimeout, d.WriteTimeout, d.IdleTimeout = time.Second, 2*time.Second, 3*time.Second
+	})
+	// Read once DHI0 has returned, as it configures the servers after reporting its startup
+	manager.DaemonShutDown()
+	if len(d.Servers) == 0 {
+		t.Fatalf("no server created")
+	}
+	for _, server := range d.Servers {
+		if server.ReadHeaderTimeout != time.Second || server.WriteTimeout != 2*time.Second || server.IdleTimeout != 3*time.Second {
+			t.Errorf("timeouts not applied: %v, %v, %v", server.ReadHeaderTimeout, server.WriteTimeout, server.IdleTimeout)
+		}
+	}
+}
+
+func TestDHIServesPreBoundListener(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	get := func() {
+		t.Helper()
+		for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+			resp, err := http.Get("http://" + listener.Addr().String() + "/")
+			if err == nil {
+				resp.Body.Close()
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("listener not served: %v", err)
+			}
+		}
+	}
+
+	_, manager := startDHI(t, func(d *DHI) {
+		d.Addr1 = ""
+		d.Listeners = map[string]net.Listener{"http": listener}
+	})
+	get()
+
+	// The pre-bound listener outlives a run of DHI
+	daemon := manager.Daemons[0]
+	manager.DaemonStop(daemon)
+	if !manager.DaemonStart(daemon) {
+		t.Fatalf("DHI0 did not start again: %+v", daemon.Snapshot())
+	}
+	get()
+	manager.DaemonShutDown()
+}
+
+func TestDHIReloadsCertificateOfPreBoundHTTPS(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	cert := "tls.crt"
+	d, manager := startDHI(t, func(d *DHI) {
+		d.Addr1 = ""
+		d.Listeners = map[string]net.Listener{"https": listener}
+		d.TLSCert, d.TLSKey = "tls.crt", "tls.key"
+		d.ConfigSource = func() (*DHI, error) {
+			reloaded := NewDHI(DefaultConfig())
+			reloaded.TLSCert, reloaded.TLSKey = cert, "tls.key"
+			return reloaded, nil
+		}
+	})
+	defer manager.DaemonShutDown()
+	d.ConfMutex.RLock()
+	loaded := d.Certificate
+	d.ConfMutex.RUnlock()
+
+	result, err := manager.DaemonCommand("DHI0", daemoncore.CommandReload, time.Second)
+	if err != nil || result.Code != 200 {
+		t.Fatalf("expected reload to succeed, got %+v, %v", result, err)
+	}
+	d.ConfMutex.RLock()
+	reloaded := d.Certificate
+	d.ConfMutex.RUnlock()
+	if reloaded == nil || reloaded == loaded {
+		t.Errorf("expected the certificate to be reloaded")
+	}
+
+	cert = "missing.crt"
+	result, err = manager.DaemonCommand("DHI0", daemoncore.CommandReload, time.Second)
+	if err != nil || result.Code != 500 || !strings.Contains(result.Note, "TLS certificate not loaded") {
+		t.Errorf("expected reload to fail on a missing certificate, got %+v, %v", result, err)
+	}
+}
+
+func TestDHIResponseStatus(t *testing.T) {
+	d := NewDHI(DefaultConfig())
+	serve := func(body string) (int, int) {
+		t.Helper()
+		recorder := httptest.NewRecorder()
+		d.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		var envelope struct{ ExecutionOutcomeCode int }
+		if err := json.Unmarshal(recorder.Body.Bytes(), &envelope); err != nil {
+			t.Fatalf("response is not an envelope: %v", err)
+		}
+		return recorder.Code, envelope.ExecutionOutcomeCode
+	}
+
+	if status, code := serve(`{"SrID": ""}`); status != 400 || code != 400 {
+		t.Errorf("expected the outcome code as HTTP status, got %d for %d", status, code)
+	}
+	if status, code := serve(`{`); status != 400 || code != 400 {
+		t.Errorf("expected invalid JSON to be sent as 400, got %d for %d", status, code)
+	}
+	d.StatusMap = map[int]int{400: 422}
+	if status, code := serve(`{"SrID": ""}`); status != 422 || code != 400 {
+		t.Errorf("expected the mapped HTTP status, got %d for %d", status, code)
+	}
+	d.StatusMode = StatusLegacy
+	if status, code := serve(`{"SrID": ""}`); status != 200 || code != 400 {
+		t.Errorf("expected legacy mode to always send 200, got %d for %d", status, code)
+	}
+}
+
+func TestDHIDeclaredResponseCodes(t *testing.T) {
+	d := NewDHI(DefaultConfig())
+	outcome := func(code int, note string) func(*http.Request, string, map[string]any) (int, string, any) {
+		return func(*http.Request, string, map[string]any) (int, string, any) { return code, note, nil }
+	}
+	d.SPRegister = []*DHI0_SP{
+		{Code: "upstream", Program: outcome(502, "upstream down"), Codes: []DHI0_Code{{Code: 502, Meaning: "Upstream down"}}},
+		{Code: "custom", Program: outcome(409, "booked"), Codes: []DHI0_Code{{Code: 409, Error: "slot_taken", Meaning: "Slot taken"}}},
+		{Code: "undeclared", Program: outcome(503, "maintenance")},
+	}
+	serve := func(srID string) (int, map[string]any) {
+		t.Helper()
+		recorder := httptest.NewRecorder()
+		d.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"SrID": "`+srID+`"}`)))
+		envelope := map[string]any{}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &envelope); err != nil {
+			t.Fatalf("response is not an envelope: %v", err)
+		}
+		return recorder.Code, envelope
+	}
+
+	if status, envelope := serve("upstream"); status != 502 || envelope["ExecutionOutcomeError"] != "upstream_failed" || envelope["ExecutionOutcomeNote"] != "upstream down" {
+		t.Errorf("expected the declared 502 with its note and registered identifier, got %d %v", status, envelope)
+	}
+	if status, envelope := serve("custom"); status != 409 || envelope["ExecutionOutcomeError"] != "slot_taken" {
+		t.Errorf("expected the declared identifier, got %d %v", status, envelope)
+	}
+	if status, envelope := serve("undeclared"); status != 500 || envelope["ExecutionOutcomeError"] != "undeclared_outcome_code" {
+		t.Errorf("expected an undeclared code to be sent as 500, got %d %v", status, envelope)
+	}
+	if status, envelope := serve("unknown"); status != 400 || envelope["ExecutionOutcomeError"] != "bad_request" {
+		t.Errorf("expected an unknown service to be a bad request, got %d %v", status, envelope)
+	}
+}
+
+func TestDHIErrorObject(t *testing.T) {
+	d := NewDHI(DefaultConfig())
+	d.SPRegister = []*DHI0_SP{
+		{Code: "fields", Program: func(*http.Request, string, map[string]any) (int, string, any) {
+			return DHI0_Fail(400, "Seed invalid", DHI0_FieldError("city", "required", "missing city"), DHI0_FieldError("dates.end", "invalid_date", "not a date"))
+		}},
+		{Code: "busy", Codes: []DHI0_Code{{Code: 503}}, Program: func(*http.Request, string, map[string]any) (int, string, any) {
+			return DHI0_RetryLater(503, "maintenance", 1500*time.Millisecond)
+		}},
+		{Code: "panic", Program: func(*http.Request, string, map[string]any) (int, string, any) {
+			panic("boom")
+		}},
+	}
+	serve := func(srID string) (*httptest.ResponseRecorder, map[string]any) {
+		t.Helper()
+		recorder := httptest.NewRecorder()
+		d.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"SrID": "`+srID+`"}`)))
+		var envelope struct {
+			Yield any
+			Error map[string]any
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &envelope); err != nil {
+			t.Fatalf("response is not an envelope: %v", err)
+		}
+		if envelope.Yield != nil {
+			t.Errorf("error object sent as yield: %s", recorder.Body.String())
+		}
+		return recorder, envelope.Error
+	}
+
+	_, failure := serve("fields")
+	fields, _ := failure["fields"].([]any)
+	if failure["code"] != "bad_request" || failure["message"] != "Seed invalid" || len(fields) != 2 {
+		t.Fatalf("expected both field errors, got %v", failure)
+	}
+	if field := fields[1].(map[string]any); field["field"] != "dates.end" || field["code"] != "invalid_date" {
+		t.Errorf("unexpected field error %v", field)
+	}
+
+	recorder, failure := serve("busy")
+	if failure["retry_after"] != 2.0 || recorder.Header().Get("Retry-After") != "2" || failure["code"] != "unavailable" {
+		t.Errorf("expected a retry-after hint of 2s, got %v %v", failure, recorder.Header())
+	}
+
+	recorder, failure = serve("panic")
+	if incident, _ := failure["incident"].(string); len(incident) != 16 || failure["code"] != "internal_error" {
+		t.Errorf("expected an incident ID, got %v", failure)
+	}
+	if strings.Contains(recorder.Body.String(), "boom") {
+		t.Errorf("panic leaked into the response: %s", recorder.Body.String())
+	}
+}
+
+func TestDHISeedSchema(t *testing.T) {
+	d := NewDHI(DefaultConfig())
+	fields := func(code int, yield any) []string {
+		t.Helper()
+		failure, ok := yield.(*DHI0_Error)
+		if code != 400 || !ok {
+			t.Fatalf("expected the Seed to be rejected, got %d %v", code, yield)
+		}
+		paths := []string{}
+		for _, field := range failure.Fields {
+			paths = append(paths, field.Field+" "+field.Code)
+		}
+		return paths
+	}
+
+	// The weather schema is checked before SPWeatherForecast, which would otherwise geocode the city
+	code, _, yield := d.Route(nil, &DHI0_Request{SrID: "weather", Seed: map[string]any{"city": " ", "end_date": "24.12.2025", "data_type": "daily"}}, nil)
+	if got := strings.Join(fields(code, yield), ","); got != "city pattern,data_type enum,end_date date_format,start_date required" {
+		t.Errorf("expected every violation at once, got %s", got)
+	}
+
+	schema := &DHI0_Schema{Strict: true, Fields: map[string]DHI0_Field{
+		"days":  {Type: FieldInteger, Min: DHI0_Bound(1), Max: DHI0_Bound(16)},
+		"place": {Type: FieldObject, Fields: map[string]DHI0_Field{"lat": {Type: FieldNumber, Required: true}}},
+		"tags":  {Type: FieldArray, Items: &DHI0_Field{Type: FieldString}},
+	}}
+	if err := schema.Check(); err != nil {
+		t.Fatalf("unexpected schema error: %v", err)
+	}
+	d.SPRegister = []*DHI0_SP{{Code: "strict", Schema: schema, Program: SP01}}
+	seed := map[string]any{"days": 1.5, "place": map[string]any{"lon": 3.4}, "tags": []any{"a", 2.0}, "extra": true}
+	code, _, yield = d.Route(nil, &DHI0_Request{SrID: "strict", Seed: seed}, nil)
+	if got := strings.Join(fields(code, yield), ","); got != "days type,place.lat required,place.lon unknown_field,tags[1] type,extra unknown_field" {
+		t.Errorf("unexpected violations %s", got)
+	}
+	if code, _, _ := d.Route(nil, &DHI0_Request{SrID: "strict", Seed: map[string]any{"days": 3.0}}, nil); code != 200 {
+		t.Errorf("expected a valid Seed to reach the program, got %d", code)
+	}
+
+	if err := (&DHI0_Schema{Fields: map[string]DHI0_Field{"when": {Type: "time", Pattern: "("}}}).Check(); err == nil {
+		t.Errorf("expected an unknown type and a broken pattern to be reported")
+	}
+	// A schema that skipped Check: the broken pattern isn't applied and the enum of a date is ignored, as Check rejects both
+	unchecked := &DHI0_Schema{Fields: map[string]DHI0_Field{
+		"city": {Type: FieldString, Pattern: "("},
+		"day":  {Type: FieldDate, Enum: []string{"2025-12-24"}},
+	}}
+	if errs := unchecked.Validate(map[string]any{"city": "Lagos", "day": "2025-12-25"}); len(errs) != 0 {
+		t.Errorf("expected the unusable constraints to be skipped, got %+v", errs)
+	}
+
+	code, _, yield = d.Route(nil, &DHI0_Request{SrID: DHI0_DiscoverySrID}, nil)
+	services, _ := yield.([]DHI0_ServiceDoc)
+	if code != 200 || len(services) != 1 || services[0].Schema != schema || len(services[0].Codes) != 4 {
+		t.Errorf("expected the discovery to list the service with its schema and codes, got %d %+v", code, yield)
+	}
+}
+
+type typedForecastIn struct {
+	City  string   `json:"city" required:"true" pattern:"\\S"`
+	Days  int      `json:"days" min:"1" max:"16"`
+	Units *string  `json:"units" enum:"metric,imperial"`
+	From  string   `json:"from" format:"2006-01-02"`
+	Tags  []string `json:"tags"`
+	Place struct {
+		Lat float64 `json:"lat" required:"true"`
+	} `json:"place"`
+	internal bool
+}
+
+type typedForecastOut struct {
+	City string `json:"city"`
+	Days int    `json:"days"`
+}
+
+func TestWeatherGeocodingFailures(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("name") {
+		case "Nowhere":
+			fmt.Fprint(w, `{"results": []}`)
+		case "Slow":
+			<-r.Context().Done()
+		default:
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+		}
+	}))
+	defer upstream.Close()
+	defer func(endpoint string) { geocodingEndpoint = endpoint }(geocodingEndpoint)
+	geocodingEndpoint = upstream.URL
+
+	forecast := func(city string, timeout time.Duration) (int, *DHI0_Error) {
+		t.Helper()
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		r := httptest.NewRequest(http.MethodPost, "/", nil).WithContext(ctx)
+		code, _, yield := SPWeatherForecast(r, "weather", map[string]any{"city": city, "start_date": "2025-12-24", "end_date": "2025-12-24"})
+		failure, _ := yield.(*DHI0_Error)
+		return code, failure
+	}
+	if code, failure := forecast("Nowhere", time.Second); code != 400 || failure == nil || len(failure.Fields) != 1 || failure.Fields[0].Code != "geocoding_failed" {
+		t.Errorf("an unknown city should be a field error, got %d %+v", code, failure)
+	}
+	if code, failure := forecast("Busy", time.Second); code != 502 || failure == nil || failure.RetryAfter != 30 || len(failure.Fields) != 0 {
+		t.Errorf("a failing geocoding service should be retried later, got %d %+v", code, failure)
+	}
+	if code, failure := forecast("Slow", 50*time.Millisecond); code != 504 || failure == nil || failure.RetryAfter != 30 {
+		t.Errorf("a geocoding timeout should be a 504, got %d %+v", code, failure)
+	}
+}
+
+func TestRegisterTyped(t *testing.T) {
+	defer func(register []*DHI0_SP) { DHI0_SPRegister = register }(DHI0_SPRegister)
+	sp := RegisterTyped("typed", func(ctx context.Context, in typedForecastIn) (typedForecastOut, error) {
+		switch in.City {
+		case "Atlantis":
+			return typedForecastOut{}, DHI0_Failed(404, "no such city", DHI0_FieldError("city", "not_found", "no such city"))
+		case "Slow":
+			return typedForecastOut{}, fmt.Errorf("geocoding: %w", context.DeadlineExceeded)
+		case "Broken":
+			return typedForecastOut{}, errors.New("bug")
+		}
+		return typedForecastOut{City: in.City, Days: in.Days}, nil
+	}, DHI0_Code{Code: 404, Meaning: "Unknown city"})
+	if DHI0_SPRegister[len(DHI0_SPRegister)-1] != sp {
+		t.Fatalf("typed service provider not registered")
+	}
+	if err := sp.Schema.Check(); err != nil || !sp.Schema.Strict || sp.Schema.Fields["days"].Type != FieldInteger ||
+		sp.Schema.Fields["from"].Type != FieldDate || sp.Schema.Fields["place"].Fields["lat"].Type != FieldNumber {
+		t.Errorf("unexpected schema %+v, %v", sp.Schema, err)
+	}
+	if _, ok := sp.Schema.Fields["internal"]; ok {
+		t.Errorf("unexported fields belong to no schema")
+	}
+
+	d := NewDHI(DefaultConfig())
+	d.SPRegister = []*DHI0_SP{sp}
+	route := func(seed map[string]any) (int, string, any) {
+		return d.Route(nil, &DHI0_Request{SrID: "typed", Seed: seed}, nil)
+	}
+	place := map[string]any{"lat": 6.5}
+
+	code, _, yield := route(map[string]any{"city": "Lagos", "days": 3.0, "place": place})
+	if out, ok := yield.(typedForecastOut); code != 200 || !ok || out.City != "Lagos" || out.Days != 3 {
+		t.Errorf("expected the decoded Seed to reach the program, got %d %+v", code, yield)
+	}
+	code, _, yield = route(map[string]any{"days": 30.0, "units": "kelvin", "extra": 1.0, "place": place})
+	if failure, ok := yield.(*DHI0_Error); code != 400 || !ok || len(failure.Fields) != 4 {
+		t.Errorf("expected the schema of In to reject the Seed, got %d %+v", code, yield)
+	}
+	code, note, yield := route(map[string]any{"city": "Atlantis", "place": place})
+	if failure, ok := yield.(*DHI0_Error); code != 404 || note != "no such city" || !ok || failure.Fields[0].Code != "not_found" {
+		t.Errorf("expected the typed failure as outcome, got %d %q %+v", code, note, yield)
+	}
+	if code, _, _ := route(map[string]any{"city": "Slow", "place": place}); code != 504 {
+		t.Errorf("expected a deadline to be a 504, got %d", code)
+	}
+	if code, _, _ := route(map[string]any{"city": "Broken", "place": place}); code != 500 {
+		t.Errorf("expected another error to be a 500, got %d", code)
+	}
+	if _, ok := sp.Declared(504); !ok {
+		t.Errorf("504 should be declared for typed service providers")
+	}
+}
+
+type typedPaging struct {
+	Page  int    `json:"page" min:"1"`
+	Token string `json:"token"`
+}
+
+type typedTokens struct {
+	Token string `json:"token"`
+}
+
+func TestSchemaOfEmbeddedFields(t *testing.T) {
+	schema := SchemaOf[struct {
+		typedPaging
+		*typedTokens
+		Place typedPaging `json:"place"`
+		Data  []byte      `json:"data"`
+		City  string      `json:"city"`
+	}]()
+	names := []string{}
+	for name := range schema.Fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	// token is tied between the two embedded structs, so encoding/json ignores it too
+	if got := strings.Join(names, ","); got != "city,data,page,place" {
+		t.Errorf("expected the fields of the embedded struct to be promoted, got %s", got)
+	}
+	if schema.Fields["page"].Min == nil || schema.Fields["place"].Type != FieldObject {
+		t.Errorf("unexpected promoted fields %+v", schema.Fields)
+	}
+	if data := schema.Fields["data"]; data.Type != FieldString || data.Items != nil {
+		t.Errorf("expected []byte to be a base64 string, got %+v", data)
+	}
+	if errs := schema.Validate(map[string]any{"page": 2.0, "data": "aGk="}); len(errs) != 0 {
+		t.Errorf("unexpected violations %+v", errs)
+	}
+	if errs := schema.Validate(map[string]any{"data": []any{104.0, 105.0}}); len(errs) != 1 || errs[0].Code != "type" {
+		t.Errorf("expected an array of bytes to be rejected, got %+v", errs)
+	}
+}
+
+func TestSchemaOfRejectsUnsupportedTypes(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected a map field to be rejected")
+		}
+	}()
+	SchemaOf[struct {
+		Labels map[string]string `json:"labels"`
+	}]()
+}
@@ -1,13 +1,30 @@
 package main
 
 import  "syscall"
+import  "time"
 
-var     DaemonRegister []*Daemon = [ ]*Daemon {
-	&Daemon { Name: "DHI0" },
+import  "github.com/PreciousM01/Precious-Lytup-Backend/DHI/daemoncore"
+
+var     DaemonRegister []*daemoncore.Daemon = [ ]*daemoncore.Daemon {
+	&daemoncore.Daemon { Name: "DHI0", ShutdownGrace: time.Second * 30, Critical: true, HeartbeatTimeout: time.Second * 30 },
 }
 var     SupportedShutdownSignal []syscall.Signal = []syscall.Signal {
-	syscall.SIGHUP ,
 	syscall.SIGINT ,
 	syscall.SIGTERM,
 }
-var     TimeZoneSecondOffset int = 0
+var     SupportedReloadSignal []syscall.Signal = []syscall.Signal {
+	syscall.SIGHUP ,
+}
+// Signals that leave the process running, see DiagnosticHandlers
+var     StateDumpSignal syscall.Signal = syscall.SIGUSR1
+var     DebugToggleSignal syscall.Signal = syscall.SIGUSR2
+var     StackDumpSignal syscall.Signal = syscall.SIGQUIT
+var     ControlSocket string = "lytup.sock"  // admin control socket, see cmd/lytupctl
+var     DumpDir string = "."  // goroutine dumps of hung daemons, see DumpGoroutines
+var     JournalFile string = "lytup.journal"  // daemon lifecycle events, one JSON object per line, see Manager.Journal
+var     JournalMaxSizeMB int = 10
+var     JournalMaxBackups int = 3
+var     TimeZone string = "UTC"  // IANA name used for log timestamps
+var     LoggLevelName string = "info"
+var     LoggFormat string = "text"
+var     LoggSources []string = []string { "Main", "Manager", "DHI1", "DHI2", "Cache", "Weather" }
@@ -0,0 +1,37 @@
+package main
+
+import  "fmt"
+import  "syscall"
+
+import  "github.com/PreciousM01/Precious-Lytup-Backend/DHI/daemoncore"
+
+/* Handlers of the diagnostic signals, run by Supervise without stopping the process:
+ * StateDumpSignal logs every daemon's state, DHI's in-flight requests and the cache stats;
+ * DebugToggleSignal switches debug logging on and off; StackDumpSignal dumps the goroutines to a file in DumpDir
+*/
+func    DiagnosticHandlers (m *daemoncore.Manager, d *DHI) map[syscall.Signal]func () {
+	return map[syscall.Signal]func () {
+		StateDumpSignal: func () {
+			m.LogState ()
+			xc05 := d.InFlight ()
+			daemoncore.Logg.Info ("DHI1", "In-flight requests", "http", xc05 ["http"], "https", xc05 ["https"])
+			xc10 := GlobalWeatherCache.GetStats ()
+			daemoncore.Logg.Info ("Cache", "Stats", "total", xc10 ["total"], "fresh", xc10 ["fresh"], "stale", xc10 ["stale"], "expired", xc10 ["expired"])
+		},
+		DebugToggleSignal: func () {
+			if daemoncore.Logg.ToggleDebug () {
+				daemoncore.Logg.Info ("Manager", "Debug logging on")
+			} else {
+				daemoncore.Logg.Info ("Manager", "Debug logging off")
+			}
+		},
+		StackDumpSignal: func () {
+			xc05, xc10 := m.DumpGoroutines ("signal")
+			if xc10 != nil {
+				daemoncore.Output_Logg ("ERR", "Manager", fmt.Sprintf ("Goroutines not dumped [%s]", xc10.Error ()))
+				return
+			}
+			daemoncore.Logg.Info ("Manager", "Goroutines dumped", "path", xc05)
+		},
+	}
+}
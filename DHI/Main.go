@@ -5,290 +5,136 @@ package main
 	Version: 0.0.3
 */
 
+import  "context"
 import  "fmt"
+import  "net"
 import  "os"
-import  "os/signal"
-import  "runtime/debug"
 import  "slices"
-import  "strings"
 import  "time"
-import  "syscall"
 
+import  "github.com/PreciousM01/Precious-Lytup-Backend/DHI/daemoncore"
+
+func    main () {
+	os.Exit (run ())
+}
 
 /* Initialize Daemoncore and start the application
  * Creates a DHI instance, assigns it to be the program of the daemon
- * Initialize daemon manager, starts execution and blocks unti an OS shutdown signal is received which shuts down the application.
+ * Runs the registered daemons and the cache jobs with daemoncore until an OS shutdown signal is received which shuts down the application.
+ * Returns the process exit code
 */
-func    main () {
-	
+func    run () int {
+
 	/***1***/
-	Output_Logg ("OUT", "Main", "PROJECT: Starting up")
+	daemoncore.Output_Logg ("OUT", "Main", "PROJECT: Starting up")
 
 	// If there are no daemons running, shut down.
 	if DaemonRegister == nil{
-		Output_Logg ("OUT", "Main", "PROJECT: No Daemon(s) to run. Shutting down now")
-		return
+		daemoncore.Output_Logg ("OUT", "Main", "PROJECT: No Daemon(s) to run. Shutting down now")
+		return daemoncore.ExitClean
+	}
+
+	// Load the runtime configuration (file, LYTUP_* environment variables, flags)
+	conf, err := LoadConfig(os.Args[1:])
+	if err != nil {
+		daemoncore.Output_Logg("ERR", "Main", fmt.Sprintf("PROJECT: Configuration not loaded [%s]", err.Error()))
+		return daemoncore.ExitStartupFailed
 	}
+	if err := daemoncore.Logg.Configure(conf.Logging); err != nil {
+		daemoncore.Output_Logg("ERR", "Main", fmt.Sprintf("PROJECT: Logging not configured [%s]", err.Error()))
+		return daemoncore.ExitStartupFailed
+	}
+	GlobalWeatherCache.FilePath = conf.Cache.FilePath
+	GlobalWeatherCache.TTL = time.Duration(conf.Cache.TTL)
+	GlobalWeatherCache.CleanupInterval = time.Duration(conf.Cache.CleanupInterval)
+	GlobalWeatherCache.SaveInterval = time.Duration(conf.Cache.SaveInterval)
+	GlobalWeatherCache.WarmInterval = time.Duration(conf.Cache.WarmInterval)
+	conf.ApplyDaemons(DaemonRegister)
 
 	// Load persistent cache on startup
 	if err := GlobalWeatherCache.Load(); err != nil {
-		Output_Logg("ERR", "Main", fmt.Sprintf("Failed to load cache: %s", err.Error()))
+		daemoncore.Output_Logg("ERR", "Main", fmt.Sprintf("Failed to load cache: %s", err.Error()))
 	}
 
-	//Creating a new DHI object
-	d := NewDHI()
-
-	// Running the daemon with DHI
-	DaemonRegister[0].Program = d.DHIStart
-
-	manager := &DaemonManager{
-		Daemons: 		DaemonRegister,
-		SignalCh: 		make(chan os.Signal),
-		StatusCh: 		make(chan bool),
-		ShutdownSignal: SupportedShutdownSignal,
+	//Creating a new DHI object, re-reading the configuration on reload
+	d := NewDHI(conf)
+	// Listeners handed over by an upgrade, else passed in by systemd socket activation (FileDescriptorName=http / https),
+	// else bound here so they can be handed over on the next upgrade
+	listeners, err := daemoncore.InheritedListeners()
+	if err == nil && len(listeners) == 0 {
+		listeners, err = daemoncore.SystemdListeners()
 	}
-
-	/***2***/
-	defer func() {
-		Output_Logg("OUT", "Main", "PROJECT: Initiating graceful shutdown")
-		
-		// Save cache before shutting down daemons
-		if err := GlobalWeatherCache.Save(); err != nil {
-			Output_Logg("ERR", "Main", fmt.Sprintf("Failed to save cache: %s", err.Error()))
-		}
-		
-		// Shutdown daemons
-		manager.DaemonShutDown()
-		
-		Output_Logg("OUT", "Main", "PROJECT: Shutdown complete")
-	}()
-
-	/***4***/
-	go manager.DaemonStartUp()
-	
-	/***5***/
-	go manager.Supervise(manager.SignalCh, manager.StatusCh)
-
-	// Wait until all goroutines have shutdown
-	<- manager.SignalCh
-}
-
-/* Gracefully stops all running daemons. 
- * Ensures reseources are released in a dependency-safe order by shutting down in a reverse order
-*/
-func (m *DaemonManager) DaemonShutDown() {
-		xc05 := m.Daemons
-		slices.Reverse(xc05)
-		for _ , xd10 := range xc05 {
-			if xd10.State != 1 { continue }
-			xd15 := map[string]string{}
-			xd15["Command"]= "shutdown"
-			xd10.clap <-  xd15
-
-			xd20 := fmt.Sprintf (
-				`PROJECT: Daemon %s: Shutdown signalledd`, xd10.Name,
-			)
-			Output_Logg ("OUT", "Main", xd20)
-
-			xd25 := make (chan bool, 1)
-			if xd10.ShutdownGrace != 0{
-				go func () {
-					time.Sleep(xd10.ShutdownGrace)
-					xd25 <- true
-				}  ( )
-			}
-
-			select  {
-				case xf05 := <- xd10.flap: {
-					if xf05["ExctnOtcmCode"] != "200" {
-						xg05 := fmt.Sprintf (
-							`PROJECT: Daemon %s: Encountered error [%s]`, xd10.Name, xf05["ExctnOtcmNote"],
-						)
-						Output_Logg ("ERR", "Main", xg05)
-						return
-					}
-				}
-				case _ = <- xd25: {}
-			}
-			xd30 := fmt.Sprintf (
-				`PROJECT: Daemon %s: Shutdown successful`, xd10.Name,
-			)
-			Output_Logg ("OUT", "Main", xd30)
+	if err != nil {
+		daemoncore.Output_Logg("ERR", "Main", fmt.Sprintf("PROJECT: Passed in sockets not usable [%s]", err.Error()))
+		return daemoncore.ExitStartupFailed
+	}
+	for name, listener := range listeners {
+		if name != "http" && name != "https" {
+			daemoncore.Output_Logg("ERR", "Main", fmt.Sprintf("PROJECT: Ignoring passed in socket %s on %s (expected http or https)", name, listener.Addr()))
+			listener.Close()
+			delete(listeners, name)
+			continue
 		}
-	} ;
-
-/* Starts all registered daemons independently. Initializes communication (flap, clap) for each daemon.
- * Launches daemon execution  and monitors for startup success or failure
-*/
-func (m *DaemonManager) DaemonStartUp() {
-		for _ , xc10 := range m.Daemons {
-		/***1***/ // Daemon has no program running
-		if xc10.Program == nil {
-			xd05 := fmt.Sprintf (
-				`PROJECT: Daemon %s: Skipping (Daemon has no program to run)`,
-				xc10.Name ,
-			)
-			Output_Logg ("OUT", "Main", xd05)
+		daemoncore.Output_Logg("OUT", "Main", fmt.Sprintf("PROJECT: Using passed in socket %s on %s", name, listener.Addr()))
+	}
+	for name, addr := range map[string]string{"http": d.Addr1, "https": d.Addr2} {
+		if addr == "" || listeners[name] != nil {
 			continue
 		}
-		/***2***/ // Starting up daemon
-		xc10.clap = make (chan map[string]string, 1)
-		xc10.flap = make (chan map[string]string, 1)
-		xc15 := fmt.Sprintf (
-			`PROJECT: Daemon %s: Starting up... Please wait`, xc10.Name,
-		)
-		Output_Logg ("OUT", "Main", xc15)
-
-		/***3***/ // 
-		xc20 := make (chan bool, 1)
-		go m.DaemonRun(xc10, xc20) 
-
-		/***4***/
-		xc30 := make (chan bool , 1)
-		go m.DaemonShutDownSignal(xc10, xc30)
-		
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			daemoncore.Output_Logg("ERR", "Main", fmt.Sprintf("PROJECT: Startup aborted [%s]", err.Error()))
+			return daemoncore.ExitStartupFailed
+		}
+		listeners[name] = listener
 	}
+	d.Listeners = listeners
+	d.ConfigSource = func() (*DHI, error) {
+		xc05, xc10 := LoadConfig(os.Args[1:])
+		if xc10 != nil {
+			return nil, xc10
+		}
+		return NewDHI(xc05), nil
 	}
 
-/* Executes a single daemon program. 
- * Takes a daemon instance and a status channel as arguments.
- * Runs the daemon program, captures panics safely, updates state, and sends the execution status to the status channel.
- * The status channel is used to signal the completion of the daemon execution (true-> done, false -> not done)
-*/
-
-func (m *DaemonManager ) DaemonRun (daemon *Daemon, status chan bool) {
-
-	defer func (  ) {
-		xc05 := recover ( )
-		if xc05 ==  nil { return }
-
-		daemon.State = 2
-		xc10 := fmt.Sprintf (
-			`Paniced [%v : %s]`, xc05, debug.Stack (),
-		)
-		xc15 := map[string]string {}
-		xc15 ["ExctnOtcmCode"] = "500"
-		xc15 ["ExctnOtcmNote"] = xc10
-		daemon.flap <- xc15
-		status <- true
-	} ( )
+	// Running the daemon with DHI
+	DaemonRegister[0].ContextProgram = d.DHIStart
 
-	daemon.State = 1
-	xb05 := daemon.Program (daemon.clap, daemon.flap)
-	daemon.State = 2
-	xb10 := map[string]string {  }
-	xb10 ["ExctnOtcmCode"] = "200"
-	if xb05 != nil {
-		xb10 ["ExctnOtcmCode"] = "500"
-		xb10 ["ExctnOtcmNote"] = xb05.Error ()
+	// Cache cleanup, saving and warming run as job daemons, after the registered daemons
+	jobs, err := GlobalWeatherCache.Jobs(refreshWeather)
+	if err != nil {
+		daemoncore.Output_Logg("ERR", "Main", fmt.Sprintf("PROJECT: Cache jobs not started [%s]", err.Error()))
+		return daemoncore.ExitStartupFailed
 	}
-	daemon.flap <- xb10
-	status <- true
-} 
 
-/* Helper function for DaemonStartUp. Handles Errors and signals during startup that indicate success or failure.
-*/
-func (m *DaemonManager) DaemonShutDownSignal(daemon *Daemon, status chan bool) {
-	if daemon.StartupGrace != 0  {
-			go func (  ) {
-				time.Sleep (daemon.StartupGrace)
-				status <- true
-			}  (  )
-		}
-		select  {
-			case xe05 := <- daemon.flap: {
-				if xe05 ["StartupCode"] != "200" {
-					xf05 := fmt.Sprintf (
-						`PROJECT: Daemon %s: Startup failed [%s]`,
-						daemon.Name , xe05 ["StartupNote"],
-					)
-					Output_Logg ("ERR", "Main", xf05)
-					return
-				}
-				break
+	/***2***/
+	var manager *daemoncore.Manager
+	manager = daemoncore.New(append(slices.Clone(DaemonRegister), jobs...),
+		daemoncore.WithShutdownSignals(SupportedShutdownSignal...),
+		daemoncore.WithReloadSignals(SupportedReloadSignal...),
+		daemoncore.WithControlSocket(conf.Control.Socket),
+		daemoncore.WithDumpDir(conf.Diagnostics.DumpDir),
+		daemoncore.WithJournal(conf.Diagnostics.Journal.Path,
+			int64(conf.Diagnostics.Journal.MaxSizeMB)*1024*1024, conf.Diagnostics.Journal.MaxBackups),
+		daemoncore.WithHandoff(func() (map[string]net.Listener, error) {
+			// The new process loads the cache on startup
+			if err := GlobalWeatherCache.Save(); err != nil {
+				return nil, err
 			}
-			case _= <- status:{
-				xe10 := fmt.Sprintf (
-					`PROJECT: Daemon %s: Startup failed [%s]`,
-					daemon.Name , "Startup grace period expired",
-				)
-				Output_Logg ("ERR", "Main", xe10)
+			return d.Listeners, nil
+		}),
+		// Save cache before shutting down daemons, unless the new process of an upgrade has loaded it already
+		daemoncore.WithShutdownHook(func() {
+			if manager.Upgraded() {
 				return
 			}
-		}
-		
-	}
-
-/* Monitors all daemons and  listens for OS shutdown signals.
- * Takes a signal channel and a status channel as arguments.
- * Waits for shutdown signals and initiates the shutdown process for all running daemons.
- * The status channel is used to signal the completion of the shutdown process
-*/
-func (m *DaemonManager) Supervise(SigChannel chan os.Signal, status chan bool) { 
-	for _ , xc10 := range SupportedShutdownSignal { signal.Notify (SigChannel , xc10) }
-	for     {
-		select  {
-			case _= <-  status:{
-				for _ , xf10 := range m.Daemons {
-					select  {
-						case xh10 := <- xf10.flap: {
-							if xh10["ExctnOtcmCode"] != "200" {
-								xi05 := fmt.Sprintf (
-									`PROJECT: Daemon %s: Encountered error [%s]`, xf10.Name , xh10["ExctnOtcmNote"],
-								)
-								Output_Logg (
-									"ERR", "Main", xi05,
-								)
-								return
-							}
-							xh15 := fmt.Sprintf (
-								`PROJECT: Daemon %s: Finished`, xf10.Name,
-							)
-							Output_Logg ("OUT", "Main", xh15)
-						}
-						default: {}
-					}
-				}
+			if err := GlobalWeatherCache.Save(); err != nil {
+				daemoncore.Output_Logg("ERR", "Main", fmt.Sprintf("Failed to save cache: %s", err.Error()))
 			}
-			case <- SigChannel:{
-				Output_Logg("OUT", "Manager", "Shutdown signal received")
-				return
-			}
-		}
-	}
-}
-//============================================================================================//
-//12345678901234567890123456789012345678901234567890123456789012345678901234567890123456789012//
-//12345678901234567890123456789012345678901234567890123456789012345678901234567890123456789012//
-//============================================================================================//
-type    Daemon struct  {
-	Name   string
-	Program  func  (<-  chan map[string]string, chan <- map[string]string) (error) // this function is DHI
-	State  uint64  // 0 - Initial; 1 - Running; 2 - Done
-	StartupGrace     time.Duration
-	ShutdownGrace    time.Duration
-	// internal use: don't set properties below
-	clap   chan map[string]string
-	flap   chan map[string]string
-}
-func    Output_Logg (Type, Source, Output string) {
-	Type  = strings.ToLower (Type)
-	xb05 := fmt.Sprintf (
-		`[%s//%s] %s`, time.Now ().In (time.FixedZone("TTT", TimeZoneSecondOffset)).Format ("2006-01-02 15:04:05.000 -07:00"), Source, Output,
+		}),
 	)
-	if Type == "out" {
-		os.Stdout.Write ([ ]byte (xb05 + "\n") )
-	}  else {
-		os.Stderr.Write ([ ]byte (xb05 + "\n") )
-	}
-}
+	manager.DiagnosticSignal = DiagnosticHandlers(manager, d)
 
-/* Coordinates the lifecycle of all daemons. References shared  communication channels and supported OS shutdown signals
- * Controls startup, supervision, error handling, and graceful shutdown
-*/
-type DaemonManager struct {
-	Daemons 		[]*Daemon
-	SignalCh 		chan os.Signal
-	StatusCh 		chan bool
-	ShutdownSignal	[]syscall.Signal
+	/***3***/
+	return manager.Run(context.Background())
 }
@@ -1,32 +1,61 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sync"
 	"time"
+
+	"github.com/PreciousM01/Precious-Lytup-Backend/DHI/daemoncore"
 )
 
 type CacheEntry struct {
-	Data      any       `json:"data"`
-	ExpiresAt time.Time `json:"expires_at"`
-	StoredAt  time.Time `json:"stored_at"`
+	Data      any          `json:"data"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	StoredAt  time.Time    `json:"stored_at"`
+	Source    *CacheSource `json:"source,omitempty"` // request the data was fetched for; entries without one aren't warmed
+}
+
+// Request an entry can be fetched again for by the warming job
+type CacheSource struct {
+	City      string `json:"city"`
+	DataType  string `json:"data_type"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
 }
 
+// Fetches the data for a source again, along with the TTL to store it for
+type CacheRefresh func(ctx context.Context, source CacheSource) (data any, ttl time.Duration, err error)
+
 type WeatherCache struct {
 	Store    map[string]CacheEntry
 	Mutex    sync.RWMutex
 	TTL      time.Duration
 	FilePath string // Path to persistent cache file
+
+	// Background jobs, see Jobs (0 - job not run)
+	CleanupInterval time.Duration
+	SaveInterval    time.Duration
+	WarmInterval    time.Duration
+
+	hits      map[string]bool // keys read since they were stored
+	hitMutex  sync.Mutex
+	saveMutex sync.Mutex // the save job and the save on shutdown may overlap
 }
 
 var GlobalWeatherCache = &WeatherCache{
-	Store:    make(map[string]CacheEntry),
-	TTL:      30 * time.Minute,
-	FilePath: "weather_cache.json",
+	Store:           make(map[string]CacheEntry),
+	TTL:             30 * time.Minute,
+	FilePath:        "weather_cache.json",
+	CleanupInterval: 10 * time.Minute,
+	SaveInterval:    5 * time.Minute,
+	WarmInterval:    5 * time.Minute,
 }
 
 // 1. Generate cache key from city and dates
@@ -51,6 +80,12 @@ func (c *WeatherCache) Get(key string) (any, bool) {
 		return nil, false
 	}
 
+	c.hitMutex.Lock()
+	if c.hits == nil {
+		c.hits = map[string]bool{}
+	}
+	c.hits[key] = true
+	c.hitMutex.Unlock()
 	return entry.Data, true
 }
 
@@ -75,19 +110,16 @@ func (c *WeatherCache) GetStale(key string, maxAge time.Duration) (any, bool, ti
 
 // 4. Store data in cache with TTL
 func (c *WeatherCache) Set(key string, data any) {
-	c.Mutex.Lock()
-	defer c.Mutex.Unlock()
-
-	now := time.Now()
-	c.Store[key] = CacheEntry{
-		Data:      data,
-		ExpiresAt: now.Add(c.TTL),
-		StoredAt:  now,
-	}
+	c.SetWithSource(key, data, c.TTL, nil)
 }
 
 // 5. Store data with custom TTL
 func (c *WeatherCache) SetWithTTL(key string, data any, ttl time.Duration) {
+	c.SetWithSource(key, data, ttl, nil)
+}
+
+// Store data with custom TTL, along with the request it was fetched for (see Warm)
+func (c *WeatherCache) SetWithSource(key string, data any, ttl time.Duration, source *CacheSource) {
 	c.Mutex.Lock()
 	defer c.Mutex.Unlock()
 
@@ -96,7 +128,11 @@ func (c *WeatherCache) SetWithTTL(key string, data any, ttl time.Duration) {
 		Data:      data,
 		ExpiresAt: now.Add(ttl),
 		StoredAt:  now,
+		Source:    source,
 	}
+	c.hitMutex.Lock()
+	delete(c.hits, key)
+	c.hitMutex.Unlock()
 }
 
 // 6. Clean up expired entries
@@ -111,25 +147,48 @@ func (c *WeatherCache) CleanExpired() {
 		age := now.Sub(entry.StoredAt)
 		if age > maxStaleAge {
 			delete(c.Store, key)
+			c.hitMutex.Lock()
+			delete(c.hits, key)
+			c.hitMutex.Unlock()
 		}
 	}
 }
 
-// 7. Start background cleanup goroutine
-func (c *WeatherCache) StartCleanup() {
-	go func() {
-		ticker := time.NewTicker(10 * time.Minute)
-		defer ticker.Stop()
-
-		for range ticker.C {
+// 7. Background jobs run as daemons: cleanup, periodic save and warming (refresh fetches entries again)
+// Jobs with a 0 interval are left out
+func (c *WeatherCache) Jobs(refresh CacheRefresh) ([]*daemoncore.Daemon, error) {
+	jobs := []struct {
+		name  string
+		every time.Duration
+		run   func(ctx context.Context) error
+	}{
+		{"CacheCleanup", c.CleanupInterval, func(ctx context.Context) error {
 			c.CleanExpired()
-			Output_Logg("OUT", "Cache", fmt.Sprintf("Cleaned expired entries. Current size: %d", len(c.Store)))
+			daemoncore.Logg.Debug("Cache", "Cleaned expired entries", "size", c.GetStats()["total"])
+			return nil
+		}},
+		{"CacheSave", c.SaveInterval, func(ctx context.Context) error { return c.Save() }},
+		{"CacheWarm", c.WarmInterval, func(ctx context.Context) error { return c.Warm(ctx, c.WarmInterval, refresh) }},
+	}
+
+	daemons := []*daemoncore.Daemon{}
+	for _, job := range jobs {
+		if job.every == 0 {
+			continue
+		}
+		daemon, err := daemoncore.NewJobDaemon(job.name, daemoncore.Job{Every: job.every, Jitter: job.every / 10, Run: job.run})
+		if err != nil {
+			return nil, err
 		}
-	}()
+		daemons = append(daemons, daemon)
+	}
+	return daemons, nil
 }
 
 // 8. Save cache to disk
 func (c *WeatherCache) Save() error {
+	c.saveMutex.Lock()
+	defer c.saveMutex.Unlock()
 	c.Mutex.RLock()
 	defer c.Mutex.RUnlock()
 
@@ -138,11 +197,28 @@ func (c *WeatherCache) Save() error {
 		return fmt.Errorf("failed to marshal cache: %w", err)
 	}
 
-	if err := os.WriteFile(c.FilePath, data, 0644); err != nil {
+	// Written to a temporary file renamed over the cache file, so a reader never sees a partial cache
+	file, err := os.CreateTemp(filepath.Dir(c.FilePath), filepath.Base(c.FilePath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := file.Chmod(0644); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := os.Rename(file.Name(), c.FilePath); err != nil {
 		return fmt.Errorf("failed to write cache file: %w", err)
 	}
 
-	Output_Logg("OUT", "Cache", fmt.Sprintf("Saved %d entries to %s", len(c.Store), c.FilePath))
+	daemoncore.Logg.Info("Cache", "Saved", "entries", len(c.Store), "file", c.FilePath)
 	return nil
 }
 
@@ -152,7 +228,7 @@ func (c *WeatherCache) Load() error {
 	defer c.Mutex.Unlock()
 
 	if _, err := os.Stat(c.FilePath); os.IsNotExist(err) {
-		Output_Logg("OUT", "Cache", "No existing cache file found. Starting with empty cache.")
+		daemoncore.Logg.Info("Cache", "No existing cache file found, starting with empty cache", "file", c.FilePath)
 		return nil
 	}
 
@@ -179,13 +255,43 @@ func (c *WeatherCache) Load() error {
 		}
 	}
 
-	Output_Logg("OUT", "Cache", fmt.Sprintf("Loaded %d valid entries from %s (%d expired entries discarded)", 
-		validCount, c.FilePath, len(tempStore)-validCount))
+	daemoncore.Logg.Info("Cache", "Loaded", "entries", validCount, "file", c.FilePath, "discarded", len(tempStore)-validCount)
 
 	return nil
 }
 
-// 10. Get cache statistics
+// 10. Refresh the entries read since they were stored that expire within the given period
+// Entries nobody asks for again are left to expire
+func (c *WeatherCache) Warm(ctx context.Context, within time.Duration, refresh CacheRefresh) error {
+	c.Mutex.RLock()
+	c.hitMutex.Lock()
+	due := map[string]CacheSource{}
+	deadline := time.Now().Add(within)
+	for key, entry := range c.Store {
+		if entry.Source != nil && c.hits[key] && entry.ExpiresAt.Before(deadline) {
+			due[key] = *entry.Source
+		}
+	}
+	c.hitMutex.Unlock()
+	c.Mutex.RUnlock()
+
+	var errs []error
+	for key, source := range due {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		data, ttl, err := refresh(ctx, source)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s (%s): %w", source.City, source.DataType, err))
+			continue
+		}
+		c.SetWithSource(key, data, ttl, &source)
+	}
+	daemoncore.Logg.Debug("Cache", "Warmed", "entries", len(due)-len(errs), "failed", len(errs))
+	return errors.Join(errs...)
+}
+
+// 11. Get cache statistics
 func (c *WeatherCache) GetStats() map[string]int {
 	c.Mutex.RLock()
 	defer c.Mutex.RUnlock()
@@ -212,4 +318,4 @@ func (c *WeatherCache) GetStats() map[string]int {
 	}
 
 	return stats
-}
\ No newline at end of file
+}
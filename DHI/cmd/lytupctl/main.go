@@ -0,0 +1,84 @@
+// lytupctl talks to the admin control socket of a running DaemonCore process.
+//
+//	lytupctl [-socket path] list
+//	lytupctl [-socket path] stop <daemon>
+//	lytupctl [-socket path] start <daemon>
+//	lytupctl [-socket path] reload [daemon]
+//	lytupctl [-socket path] upgrade
+//	lytupctl [-socket path] goroutines
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PreciousM01/Precious-Lytup-Backend/DHI/daemoncore"
+)
+
+func main() {
+	socket := os.Getenv("LYTUP_CONTROL_SOCKET")
+	if socket == "" {
+		socket = "lytup.sock"
+	}
+	flag.StringVar(&socket, "socket", socket, "path of the control socket (env LYTUP_CONTROL_SOCKET)")
+	timeout := flag.Duration("timeout", time.Minute, "how long to wait for the reply")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: lytupctl [-socket path] list | stop <daemon> | start <daemon> | reload [daemon] | upgrade | goroutines")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// 1. Build the request
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	req := daemoncore.ControlRequest{Action: args[0]}
+	if len(args) > 1 {
+		req.Daemon = args[1]
+	}
+
+	// 2. Send it
+	resp, err := daemoncore.ControlCall(socket, req, *timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lytupctl: %s\n", err.Error())
+		os.Exit(1)
+	}
+	if !resp.OK {
+		fmt.Fprintf(os.Stderr, "lytupctl: %s\n", resp.Error)
+		os.Exit(1)
+	}
+
+	// 3. Print the result
+	switch req.Action {
+	case "list":
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tPHASE\tUPTIME\tRESTARTS\tNOTE")
+		for _, daemon := range resp.Daemons {
+			note := daemon.HealthNote
+			if note == "" {
+				note = daemon.LastError
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", daemon.Name, daemon.Phase, daemon.Uptime, daemon.Restarts, note)
+		}
+		w.Flush()
+	case "reload":
+		names := []string{}
+		for name := range resp.Reloads {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			fmt.Printf("%s: %d %s\n", name, resp.Reloads[name].Code, resp.Reloads[name].Note)
+		}
+	case "goroutines":
+		fmt.Print(resp.Goroutines)
+	default:
+		fmt.Println("ok")
+	}
+}
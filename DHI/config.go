@@ -0,0 +1,536 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PreciousM01/Precious-Lytup-Backend/DHI/daemoncore"
+)
+
+// Runtime configuration. Defaults come from the compile-time globals (DHI-go-G1.conf.go, Main.conf.go, cache.go)
+// and are overridden, in order, by a JSON config file, LYTUP_* environment variables and command-line flags.
+// Every setting is addressed by its JSON path: "dhi.addr1" is LYTUP_DHI_ADDR1 in the environment and -dhi.addr1 on the command line.
+type Config struct {
+	DHI struct {
+		Addr1               string         `json:"addr1"`
+		Addr2               string         `json:"addr2"`
+		TLSCert             string         `json:"tls_cert"`
+		TLSKey              string         `json:"tls_key"`
+		RedirectHTTP        bool           `json:"redirect_http"`
+		RedirectDestination string         `json:"redirect_destination"`
+		MaxHeaderSize       int            `json:"max_header_size"`
+		ReadTimeout         ConfigDuration `json:"read_timeout"`
+		WriteTimeout        ConfigDuration `json:"write_timeout"`
+		IdleTimeout         ConfigDuration `json:"idle_timeout"`
+		AllowedResponseCode []int          `json:"allowed_response_code"`
+		ResponseHeaders     [][]string     `json:"response_headers"`
+		StatusMode          string         `json:"status_mode"` // "mapped" or "legacy" (always 200)
+		StatusMap           map[string]int `json:"status_map"`  // outcome code -> HTTP status
+		Services            []string       `json:"services"`    // codes enabled from DHI0_SPRegister
+	} `json:"dhi"`
+	Cache struct {
+		FilePath        string         `json:"file_path"`
+		TTL             ConfigDuration `json:"ttl"`
+		CleanupInterval ConfigDuration `json:"cleanup_interval"` // "0s" - job not run
+		SaveInterval    ConfigDuration `json:"save_interval"`
+		WarmInterval    ConfigDuration `json:"warm_interval"`
+	} `json:"cache"`
+	Daemons map[string]DaemonConfig `json:"daemons"`
+	Logging daemoncore.LoggConfig   `json:"logging"`
+	Control struct {
+		Socket string `json:"socket"` // "" - no control socket
+	} `json:"control"`
+	Diagnostics struct {
+		DumpDir string `json:"dump_dir"` // where goroutine dumps of hung daemons are written
+		Journal struct {
+			Path       string `json:"path"`        // JSON-lines file of daemon lifecycle events ("" - none)
+			MaxSizeMB  int    `json:"max_size_mb"` // rotate once the file reaches this size (0 - never)
+			MaxBackups int    `json:"max_backups"` // rotated files kept (0 - all)
+		} `json:"journal"`
+	} `json:"diagnostics"`
+}
+
+// Settings of a registered daemon, keyed by daemon name in Config.Daemons
+type DaemonConfig struct {
+	StartupGrace     ConfigDuration `json:"startup_grace"`
+	ShutdownGrace    ConfigDuration `json:"shutdown_grace"`
+	DependsOn        []string       `json:"depends_on"`
+	HealthInterval   ConfigDuration `json:"health_interval"`
+	Critical         bool           `json:"critical"`
+	HeartbeatTimeout ConfigDuration `json:"heartbeat_timeout"` // "0s" - the daemon doesn't send heartbeats
+	HungPolicy       string         `json:"hung_policy"`
+	Restart          struct {
+		When        string         `json:"when"`
+		Backoff     ConfigDuration `json:"backoff"`
+		MaxBackoff  ConfigDuration `json:"max_backoff"`
+		MaxRestarts int            `json:"max_restarts"`
+		Window      ConfigDuration `json:"window"`
+		Escalate    bool           `json:"escalate"`
+	} `json:"restart"`
+}
+
+// Duration written as a Go duration string ("30s", "5m") in config files, environment variables and flags
+type ConfigDuration time.Duration
+
+func (c ConfigDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(c).String())
+}
+
+func (c *ConfigDuration) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return errors.New("duration must be a string such as \"30s\"")
+	}
+	parsed, err := time.ParseDuration(raw)
+	if err != nil {
+		return err
+	}
+	*c = ConfigDuration(parsed)
+	return nil
+}
+
+// Field-level validation failures, reported together
+type ConfigError struct {
+	Fields []string // "<path>: <problem>"
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Fields, "\n  ")
+}
+
+// 1. Build the defaults from the compile-time globals
+func DefaultConfig() *Config {
+	conf := &Config{}
+	conf.DHI.Addr1 = DHI0_Addr1
+	conf.DHI.Addr2 = DHI0_Addr2
+	conf.DHI.TLSCert = DHI0_Addr2_Crt
+	conf.DHI.TLSKey = DHI0_Addr2_Key
+	conf.DHI.RedirectHTTP = DHI0_RedirectHTTP
+	conf.DHI.RedirectDestination = DHI0_RedirectDestination
+	conf.DHI.MaxHeaderSize = DHI0_MaxHeaderSize
+	conf.DHI.ReadTimeout = ConfigDuration(DHI0_ReadTimeout)
+	conf.DHI.WriteTimeout = ConfigDuration(DHI0_WrttTimeout)
+	conf.DHI.IdleTimeout = ConfigDuration(DHI0_IdleTimeout)
+	conf.DHI.AllowedResponseCode = slices.Clone(DNI0_AllowedResponseCode)
+	conf.DHI.ResponseHeaders = slices.Clone(DNI0_ResponseHeaders)
+	conf.DHI.StatusMode = DHI0_StatusMode
+	conf.DHI.StatusMap = maps.Clone(DHI0_StatusMap)
+	for _, sp := range DHI0_SPRegister {
+		conf.DHI.Services = append(conf.DHI.Services, sp.Code)
+	}
+
+	conf.Cache.FilePath = GlobalWeatherCache.FilePath
+	conf.Cache.TTL = ConfigDuration(GlobalWeatherCache.TTL)
+	conf.Cache.CleanupInterval = ConfigDuration(GlobalWeatherCache.CleanupInterval)
+	conf.Cache.SaveInterval = ConfigDuration(GlobalWeatherCache.SaveInterval)
+	conf.Cache.WarmInterval = ConfigDuration(GlobalWeatherCache.WarmInterval)
+
+	conf.Daemons = map[string]DaemonConfig{}
+	for _, daemon := range DaemonRegister {
+		daemonConf := DaemonConfig{
+			StartupGrace:   ConfigDuration(daemon.StartupGrace),
+			ShutdownGrace:  ConfigDuration(daemon.ShutdownGrace),
+			DependsOn:      slices.Clone(daemon.DependsOn),
+			HealthInterval: ConfigDuration(daemon.HealthInterval),
+			Critical:       daemon.Critical,
+		}
+		daemonConf.HeartbeatTimeout = ConfigDuration(daemon.HeartbeatTimeout)
+		daemonConf.HungPolicy = string(daemon.HungPolicy)
+		daemonConf.Restart.When = string(daemon.Restart.When)
+		daemonConf.Restart.Backoff = ConfigDuration(daemon.Restart.Backoff)
+		daemonConf.Restart.MaxBackoff = ConfigDuration(daemon.Restart.MaxBackoff)
+		daemonConf.Restart.MaxRestarts = daemon.Restart.MaxRestarts
+		daemonConf.Restart.Window = ConfigDuration(daemon.Restart.Window)
+		daemonConf.Restart.Escalate = daemon.Restart.Escalate
+		conf.Daemons[daemon.Name] = daemonConf
+	}
+
+	conf.Logging.Level = LoggLevelName
+	conf.Logging.Format = LoggFormat
+	conf.Logging.TimeZone = TimeZone
+	conf.Logging.Sources = map[string]string{}
+	for _, source := range LoggSources {
+		conf.Logging.Sources[source] = ""
+	}
+
+	conf.Control.Socket = ControlSocket
+	conf.Diagnostics.DumpDir = DumpDir
+	conf.Diagnostics.Journal.Path = JournalFile
+	conf.Diagnostics.Journal.MaxSizeMB = JournalMaxSizeMB
+	conf.Diagnostics.Journal.MaxBackups = JournalMaxBackups
+	return conf
+}
+
+// 2. Load the configuration: defaults, then the JSON file, then LYTUP_* environment variables, then flags
+// The file is named by -config or LYTUP_CONFIG; without either only defaults, environment and flags apply
+func LoadConfig(args []string) (*Config, error) {
+	conf := DefaultConfig()
+	settings := configSettings(reflect.ValueOf(conf).Elem())
+
+	// Flags are parsed first to find -config, but applied last
+	flagValues := [][2]string{}
+	fs := flag.NewFlagSet("lytup", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("LYTUP_CONFIG"), "path of the JSON config file (env LYTUP_CONFIG)")
+	for _, path := range settingPaths(settings) {
+		fs.Func(path, "overrides "+path+" (env "+configEnvName(path)+")", func(value string) error {
+			flagValues = append(flagValues, [2]string{path, value})
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// File
+	if *configPath != "" {
+		data, err := os.ReadFile(*configPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := conf.decodeFile(data); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", *configPath, err)
+		}
+		// Daemons named in the file but not registered are reported by Validate
+		settings = configSettings(reflect.ValueOf(conf).Elem())
+	}
+
+	// Environment, then flags
+	problems := []string{}
+	for _, path := range settingPaths(settings) {
+		if value, ok := os.LookupEnv(configEnvName(path)); ok {
+			if err := settings[path](value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %s (from %s)", path, err.Error(), configEnvName(path)))
+			}
+		}
+	}
+	for _, flagValue := range flagValues {
+		if err := settings[flagValue[0]](flagValue[1]); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s (from -%s)", flagValue[0], err.Error(), flagValue[0]))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, &ConfigError{Fields: problems}
+	}
+
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// Decode a config file over the current values. Daemon entries are merged with their defaults rather than replaced
+func (conf *Config) decodeFile(data []byte) error {
+	var fileDaemons struct {
+		Daemons map[string]json.RawMessage `json:"daemons"`
+	}
+	if err := json.Unmarshal(data, &fileDaemons); err != nil {
+		return err
+	}
+
+	daemons := conf.Daemons
+	conf.Daemons = nil
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(conf); err != nil {
+		return err
+	}
+	conf.Daemons = daemons
+
+	for name, raw := range fileDaemons.Daemons {
+		entry := conf.Daemons[name]
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&entry); err != nil {
+			return fmt.Errorf("daemons.%s: %w", name, err)
+		}
+		conf.Daemons[name] = entry
+	}
+	return nil
+}
+
+// 3. Validate every field, collecting all problems
+func (conf *Config) Validate() error {
+	problems := []string{}
+	fail := func(path, format string, a ...any) {
+		problems = append(problems, path+": "+fmt.Sprintf(format, a...))
+	}
+
+	// DHI
+	// Both addresses may be empty when listeners are passed in by socket activation; DHI reports a missing listener at startup
+	for path, addr := range map[string]string{"dhi.addr1": conf.DHI.Addr1, "dhi.addr2": conf.DHI.Addr2} {
+		if addr == "" {
+			continue
+		}
+		if _, port, err := net.SplitHostPort(addr); err != nil {
+			fail(path, "must be host:port [%s]", err.Error())
+		} else if number, err := strconv.Atoi(port); err != nil || number < 0 || number > 65535 {
+			fail(path, "port %q is not valid", port)
+		}
+	}
+	if conf.DHI.Addr2 != "" && (conf.DHI.TLSCert == "" || conf.DHI.TLSKey == "") {
+		fail("dhi.tls_cert", "dhi.tls_cert and dhi.tls_key are required when dhi.addr2 is set")
+	}
+	if conf.DHI.RedirectHTTP && !regexp.MustCompile(`^https\:\/\/.+$`).MatchString(conf.DHI.RedirectDestination) {
+		fail("dhi.redirect_destination", "must be an https:// URL when dhi.redirect_http is set")
+	}
+	if conf.DHI.MaxHeaderSize <= 0 {
+		fail("dhi.max_header_size", "must be greater than 0")
+	}
+	for path, timeout := range map[string]ConfigDuration{
+		"dhi.read_timeout": conf.DHI.ReadTimeout, "dhi.write_timeout": conf.DHI.WriteTimeout, "dhi.idle_timeout": conf.DHI.IdleTimeout,
+	} {
+		if timeout < 0 {
+			fail(path, "must not be negative")
+		}
+	}
+	for i, code := range conf.DHI.AllowedResponseCode {
+		if code < 100 || code > 599 {
+			fail(fmt.Sprintf("dhi.allowed_response_code[%d]", i), "%d is not an HTTP status code", code)
+		}
+	}
+	if conf.DHI.StatusMode != StatusMapped && conf.DHI.StatusMode != StatusLegacy {
+		fail("dhi.status_mode", "must be %s or %s", StatusMapped, StatusLegacy)
+	}
+	for code, status := range conf.DHI.StatusMap {
+		if parsed, err := strconv.Atoi(code); err != nil || parsed < 100 || parsed > 599 {
+			fail("dhi.status_map."+code, "key is not an outcome code")
+		}
+		if status < 100 || status > 599 {
+			fail("dhi.status_map."+code, "%d is not an HTTP status code", status)
+		}
+	}
+	for i, header := range conf.DHI.ResponseHeaders {
+		if len(header) != 2 || header[0] == "" {
+			fail(fmt.Sprintf("dhi.response_headers[%d]", i), "must be a [name, value] pair")
+		}
+	}
+	for i, code := range conf.DHI.Services {
+		index := slices.IndexFunc(DHI0_SPRegister, func(sp *DHI0_SP) bool { return sp.Code == code })
+		if index < 0 {
+			fail(fmt.Sprintf("dhi.services[%d]", i), "unknown service provider %q", code)
+			continue
+		}
+		// Declared codes are compiled in, but only checked for the enabled service providers
+		declared := map[int]bool{}
+		for _, declaration := range DHI0_SPRegister[index].Codes {
+			if declaration.Code < 100 || declaration.Code > 599 || declared[declaration.Code] {
+				fail(fmt.Sprintf("dhi.services[%d]", i), "service provider %q declares %d, not an HTTP status code or twice", code, declaration.Code)
+			}
+			declared[declaration.Code] = true
+		}
+		if schema := DHI0_SPRegister[index].Schema; schema != nil {
+			if err := schema.Check(); err != nil {
+				fail(fmt.Sprintf("dhi.services[%d]", i), "service provider %q has an unusable schema [%s]", code, err.Error())
+			}
+		}
+	}
+
+	// Cache
+	if conf.Cache.FilePath == "" {
+		fail("cache.file_path", "must be set")
+	}
+	if conf.Cache.TTL <= 0 {
+		fail("cache.ttl", "must be greater than 0")
+	}
+	for path, interval := range map[string]ConfigDuration{
+		"cache.cleanup_interval": conf.Cache.CleanupInterval, "cache.save_interval": conf.Cache.SaveInterval, "cache.warm_interval": conf.Cache.WarmInterval,
+	} {
+		if interval < 0 {
+			fail(path, "must not be negative")
+		}
+	}
+
+	// Daemons
+	for name, daemonConf := range conf.Daemons {
+		path := "daemons." + name
+		if !slices.ContainsFunc(DaemonRegister, func(daemon *daemoncore.Daemon) bool { return daemon.Name == name }) {
+			fail(path, "no daemon named %q is registered", name)
+			continue
+		}
+		if daemonConf.StartupGrace < 0 {
+			fail(path+".startup_grace", "must not be negative")
+		}
+		if daemonConf.ShutdownGrace < 0 {
+			fail(path+".shutdown_grace", "must not be negative")
+		}
+		for i, dependency := range daemonConf.DependsOn {
+			if _, ok := conf.Daemons[dependency]; !ok {
+				fail(fmt.Sprintf("%s.depends_on[%d]", path, i), "no daemon named %q is registered", dependency)
+			}
+		}
+		switch daemoncore.RestartWhen(daemonConf.Restart.When) {
+		case "", daemoncore.RestartNever, daemoncore.RestartOnFailure, daemoncore.RestartAlways:
+		default:
+			fail(path+".restart.when", "must be one of never, on-failure, always")
+		}
+		if daemonConf.Restart.MaxRestarts < 0 {
+			fail(path+".restart.max_restarts", "must not be negative")
+		}
+		if daemonConf.HeartbeatTimeout < 0 {
+			fail(path+".heartbeat_timeout", "must not be negative")
+		}
+		switch daemoncore.HungPolicy(daemonConf.HungPolicy) {
+		case "", daemoncore.HungRestart, daemoncore.HungTerminate:
+		default:
+			fail(path+".hung_policy", "must be one of restart, terminate")
+		}
+	}
+
+	// Logging
+	if _, err := daemoncore.ParseLoggLevel(conf.Logging.Level); err != nil {
+		fail("logging.level", "%s", err.Error())
+	}
+	for source, level := range conf.Logging.Sources {
+		if _, err := daemoncore.ParseLoggLevel(level); level != "" && err != nil {
+			fail("logging.sources."+source, "%s", err.Error())
+		}
+	}
+	if conf.Logging.Format != "text" && conf.Logging.Format != "json" {
+		fail("logging.format", "must be text or json")
+	}
+	if _, err := time.LoadLocation(conf.Logging.TimeZone); err != nil {
+		fail("logging.time_zone", "not a known IANA time zone [%s]", err.Error())
+	}
+	if conf.Logging.File.MaxSizeMB < 0 || conf.Logging.File.MaxBackups < 0 || conf.Logging.File.MaxAgeDays < 0 {
+		fail("logging.file", "max_size_mb, max_backups and max_age_days must not be negative")
+	}
+	if conf.Diagnostics.Journal.MaxSizeMB < 0 || conf.Diagnostics.Journal.MaxBackups < 0 {
+		fail("diagnostics.journal", "max_size_mb and max_backups must not be negative")
+	}
+
+	if len(problems) > 0 {
+		slices.Sort(problems)
+		return &ConfigError{Fields: problems}
+	}
+	return nil
+}
+
+// 4. Apply the daemon settings to the registered daemons
+func (conf *Config) ApplyDaemons(register []*daemoncore.Daemon) {
+	for _, daemon := range register {
+		daemonConf, ok := conf.Daemons[daemon.Name]
+		if !ok {
+			continue
+		}
+		daemon.StartupGrace = time.Duration(daemonConf.StartupGrace)
+		daemon.ShutdownGrace = time.Duration(daemonConf.ShutdownGrace)
+		daemon.DependsOn = slices.Clone(daemonConf.DependsOn)
+		daemon.HealthInterval = time.Duration(daemonConf.HealthInterval)
+		daemon.Critical = daemonConf.Critical
+		daemon.HeartbeatTimeout = time.Duration(daemonConf.HeartbeatTimeout)
+		daemon.HungPolicy = daemoncore.HungPolicy(daemonConf.HungPolicy)
+		daemon.Restart = daemoncore.RestartPolicy{
+			When:        daemoncore.RestartWhen(daemonConf.Restart.When),
+			Backoff:     time.Duration(daemonConf.Restart.Backoff),
+			MaxBackoff:  time.Duration(daemonConf.Restart.MaxBackoff),
+			MaxRestarts: daemonConf.Restart.MaxRestarts,
+			Window:      time.Duration(daemonConf.Restart.Window),
+			Escalate:    daemonConf.Restart.Escalate,
+		}
+	}
+}
+
+// Setters for every leaf setting below value, keyed by JSON path relative to value
+func configSettings(value reflect.Value) map[string]func(string) error {
+	settings := map[string]func(string) error{}
+	switch value.Kind() {
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			name, _, _ := strings.Cut(value.Type().Field(i).Tag.Get("json"), ",")
+			for path, set := range configSettings(value.Field(i)) {
+				settings[joinConfigPath(name, path)] = set
+			}
+		}
+	case reflect.Map:
+		// Map entries aren't addressable: each setter updates a copy of the entry and stores it back
+		for _, key := range value.MapKeys() {
+			for path := range configSettings(reflect.New(value.Type().Elem()).Elem()) {
+				settings[joinConfigPath(key.String(), path)] = func(raw string) error {
+					entry := reflect.New(value.Type().Elem()).Elem()
+					entry.Set(value.MapIndex(key))
+					if err := configSettings(entry)[path](raw); err != nil {
+						return err
+					}
+					value.SetMapIndex(key, entry)
+					return nil
+				}
+			}
+		}
+	default:
+		settings[""] = func(raw string) error { return setConfigValue(value, raw) }
+	}
+	return settings
+}
+
+// Parse a raw environment/flag value into a setting. Lists take either JSON or comma-separated values
+func setConfigValue(value reflect.Value, raw string) error {
+	switch value.Addr().Interface().(type) {
+	case *ConfigDuration:
+		parsed, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(parsed))
+	case *string:
+		value.SetString(raw)
+	case *bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		value.SetBool(parsed)
+	case *int:
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		value.SetInt(int64(parsed))
+	default:
+		trimmed := strings.TrimSpace(raw)
+		if !strings.HasPrefix(trimmed, "[") && (value.Type() == reflect.TypeOf([]int{}) || value.Type() == reflect.TypeOf([]string{})) {
+			trimmed = `["` + strings.Join(strings.Split(trimmed, ","), `","`) + `"]`
+			if value.Type() == reflect.TypeOf([]int{}) {
+				trimmed = strings.ReplaceAll(trimmed, `"`, "")
+			}
+		}
+		target := reflect.New(value.Type())
+		if err := json.Unmarshal([]byte(trimmed), target.Interface()); err != nil {
+			return fmt.Errorf("must be a JSON %s", value.Type())
+		}
+		value.Set(target.Elem())
+	}
+	return nil
+}
+
+func joinConfigPath(parent, child string) string {
+	if child == "" {
+		return parent
+	}
+	return parent + "." + child
+}
+
+func settingPaths(settings map[string]func(string) error) []string {
+	paths := []string{}
+	for path := range settings {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// Environment variable of a setting: "dhi.addr1" -> LYTUP_DHI_ADDR1
+func configEnvName(path string) string {
+	return "LYTUP_" + strings.Trim(regexp.MustCompile(`[^A-Z0-9]+`).ReplaceAllString(strings.ToUpper(path), "_"), "_")
+}
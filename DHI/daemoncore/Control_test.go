@@ -0,0 +1,81 @@
+package daemoncore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestControlSocket(t *testing.T) {
+	// Unix socket paths are limited to about 100 bytes, t.TempDir can be longer
+	dir, err := os.MkdirTemp("", "lytup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "ctl.sock")
+
+	program := func(Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) error {
+		Flap <- StartupResult{Code: 200}
+		for command := range Clap {
+			if command.Kind == CommandShutdown {
+				return nil
+			}
+			Flap <- CommandResult{Command: command.Kind, Code: 200, Note: "done"}
+		}
+		return nil
+	}
+	manager := &Manager{
+		Daemons: []*Daemon{
+			{Name: "Cache", Program: program, StartupGrace: time.Second, ShutdownGrace: time.Second},
+			{Name: "DHI0", Program: program, StartupGrace: time.Second, ShutdownGrace: time.Second, DependsOn: []string{"Cache"}},
+		},
+		ControlSocket: socket,
+	}
+	if err := manager.DaemonStartUp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer manager.DaemonShutDown()
+	if err := manager.ServeControl(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer manager.RequestShutdown("test done")
+
+	call := func(action, daemon string) ControlResponse {
+		t.Helper()
+		resp, err := ControlCall(socket, ControlRequest{Action: action, Daemon: daemon}, 5*time.Second)
+		if err != nil {
+			t.Fatalf("%s %s: %v", action, daemon, err)
+		}
+		return resp
+	}
+
+	if resp := call("list", ""); !resp.OK || len(resp.Daemons) != 2 || resp.Daemons[1].Phase != PhaseRunning || resp.Daemons[1].Uptime == "" {
+		t.Errorf("unexpected list %+v", resp)
+	}
+	if resp := call("stop", "Cache"); resp.OK || !strings.Contains(resp.Error, "needed by running daemon DHI0") {
+		t.Errorf("stopping a dependency of a running daemon should fail, got %+v", resp)
+	}
+	if resp := call("stop", "DHI0"); !resp.OK || manager.Daemons[1].Snapshot().Phase != PhaseStopped {
+		t.Errorf("unexpected stop %+v, phase %s", resp, manager.Daemons[1].Snapshot().Phase)
+	}
+	if resp := call("start", "DHI0"); !resp.OK || manager.Daemons[1].Snapshot().Phase != PhaseRunning {
+		t.Errorf("unexpected start %+v", resp)
+	}
+	if resp := call("reload", "DHI0"); !resp.OK || resp.Reloads["DHI0"].Code != 200 || len(resp.Reloads) != 1 {
+		t.Errorf("unexpected reload %+v", resp)
+	}
+	if resp := call("goroutines", ""); !resp.OK || !strings.Contains(resp.Goroutines, "goroutine ") {
+		t.Errorf("unexpected goroutine dump")
+	}
+	if resp := call("restart", "DHI0"); resp.OK || !strings.Contains(resp.Error, "unknown action") {
+		t.Errorf("expected unknown action error, got %+v", resp)
+	}
+
+	second := &Manager{ControlSocket: socket}
+	if err := second.ServeControl(); err == nil {
+		t.Errorf("a socket served by another manager should not be replaced")
+	}
+}
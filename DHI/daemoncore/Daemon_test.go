@@ -0,0 +1,502 @@
+package daemoncore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestDaemonOrderDependenciesFirst(t *testing.T) {
+	manager := &Manager{
+		Daemons: []*Daemon{
+			{Name: "DHI0", DependsOn: []string{"Cache"}},
+			{Name: "Poller"},
+			{Name: "Cache"},
+		},
+	}
+
+	order, err := manager.DaemonOrder()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	names := []string{}
+	for _, daemon := range order {
+		names = append(names, daemon.Name)
+	}
+	if strings.Join(names, ",") != "Cache,DHI0,Poller" {
+		t.Errorf("unexpected order %v", names)
+	}
+}
+
+func TestDaemonOrderUnknownDependency(t *testing.T) {
+	manager := &Manager{
+		Daemons: []*Daemon{
+			{Name: "DHI0", DependsOn: []string{"Cache"}},
+		},
+	}
+
+	_, err := manager.DaemonOrder()
+	if err == nil || !strings.Contains(err.Error(), "unknown daemon Cache") {
+		t.Errorf("expected unknown dependency error, got %v", err)
+	}
+}
+
+func TestDaemonOrderCycle(t *testing.T) {
+	manager := &Manager{
+		Daemons: []*Daemon{
+			{Name: "A", DependsOn: []string{"B"}},
+			{Name: "B", DependsOn: []string{"C"}},
+			{Name: "C", DependsOn: []string{"A"}},
+		},
+	}
+
+	_, err := manager.DaemonOrder()
+	if err == nil || !strings.Contains(err.Error(), "A -> B -> C -> A") {
+		t.Errorf("expected cycle error, got %v", err)
+	}
+}
+
+func TestRestartPolicyDelay(t *testing.T) {
+	policy := RestartPolicy{When: RestartOnFailure, Backoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	for restarts, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if delay := policy.Delay(restarts); delay != expected {
+			t.Errorf("restart %d: expected %v, got %v", restarts, expected, delay)
+		}
+	}
+	if policy.Allows(false) || !policy.Allows(true) {
+		t.Errorf("on-failure policy should only restart failed daemons")
+	}
+	if (RestartPolicy{}).Allows(true) {
+		t.Errorf("zero policy should never restart")
+	}
+}
+
+func TestDaemonRestartOnFailure(t *testing.T) {
+	runs := 0
+	flaky := func(Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) error {
+		runs++
+		Flap <- StartupResult{Code: 200}
+		if runs < 3 {
+			return errors.New("crashed")
+		}
+		<-Clap
+		return nil
+	}
+	daemon := &Daemon{
+		Name: "Flaky", Program: flaky, StartupGrace: time.Second, ShutdownGrace: time.Second,
+		Restart: RestartPolicy{When: RestartOnFailure, Backoff: time.Millisecond, MaxRestarts: 5},
+	}
+	manager := &Manager{Daemons: []*Daemon{daemon}}
+
+	if err := manager.DaemonStartUp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		daemon.mutex.Lock()
+		restarts := len(daemon.restarts)
+		daemon.mutex.Unlock()
+		if restarts == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("daemon restarted %d time(s), expected 2", restarts)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	manager.DaemonShutDown()
+
+	select {
+	case <-manager.ShutdownRequested():
+		t.Errorf("restart within budget should not escalate")
+	default:
+	}
+}
+
+func TestDaemonNotRelaunchedAfterStop(t *testing.T) {
+	crash := make(chan struct{})
+	program := func(Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) error {
+		Flap <- StartupResult{Code: 200}
+		<-crash
+		return errors.New("crashed")
+	}
+	daemon := &Daemon{
+		Name: "Flaky", Program: program, StartupGrace: time.Second, ShutdownGrace: time.Second,
+		Restart: RestartPolicy{When: RestartOnFailure, Backoff: 20 * time.Millisecond},
+	}
+	manager := &Manager{Daemons: []*Daemon{daemon}}
+	if err := manager.DaemonStartUp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A stop that begins once the backoff is over, before the relaunch: DaemonStop holds the lifecycle and halts the daemon
+	manager.lifecycle.Lock()
+	close(crash)
+	time.Sleep(100 * time.Millisecond)
+	daemon.mutex.Lock()
+	close(daemon.halt)
+	daemon.mutex.Unlock()
+	manager.lifecycle.Unlock()
+
+	time.Sleep(100 * time.Millisecond)
+	if snapshot := daemon.Snapshot(); snapshot.Restarts != 0 || snapshot.Phase != PhaseFailed {
+		t.Errorf("a halted daemon should not be relaunched, got %+v", snapshot)
+	}
+}
+
+func TestDaemonRestartBudgetEscalates(t *testing.T) {
+	daemon := &Daemon{
+		Name: "Broken", StartupGrace: time.Second,
+		Program: func(Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) error {
+			Flap <- StartupResult{Code: 200}
+			return errors.New("crashed")
+		},
+		Restart: RestartPolicy{When: RestartAlways, Backoff: time.Millisecond, MaxRestarts: 2, Window: time.Minute, Escalate: true},
+	}
+	manager := &Manager{Daemons: []*Daemon{daemon}}
+
+	if err := manager.DaemonStartUp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case <-manager.ShutdownRequested():
+	case <-time.After(2 * time.Second):
+		t.Fatalf("used up restart budget did not escalate")
+	}
+	manager.DaemonShutDown()
+}
+
+func TestMapProgramAdapter(t *testing.T) {
+	// Same contract as the samples in daemon/Test.go
+	healthy := func(Clap <-chan map[string]string, Flap chan<- map[string]string) (E error) {
+		Flap <- map[string]string{"StartupCode": "200", "StartupNote": "OK"}
+		<-Clap
+		return
+	}
+	failing := func(Clap <-chan map[string]string, Flap chan<- map[string]string) (E error) {
+		Flap <- map[string]string{"StartupCode": "500", "StartupNote": "Not OK"}
+		return
+	}
+	manager := &Manager{
+		Daemons: []*Daemon{
+			{Name: "Healthy", Program: MapProgram(healthy), StartupGrace: time.Second, ShutdownGrace: time.Second},
+			{Name: "Failing", Program: MapProgram(failing), StartupGrace: time.Second},
+			{Name: "Dependent", Program: MapProgram(healthy), DependsOn: []string{"Failing"}},
+		},
+	}
+
+	if err := manager.DaemonStartUp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if manager.Daemons[2].clap != nil {
+		t.Errorf("daemon depending on a failed daemon should not start")
+	}
+
+	result, err := manager.DaemonCommand("Healthy", CommandReload, time.Second)
+	if err != nil || result.Code != 501 {
+		t.Errorf("expected map based program to refuse reload, got %+v, %v", result, err)
+	}
+
+	manager.DaemonShutDown()
+	snapshot := manager.Snapshot()
+	if snapshot[0].Phase != PhaseStopped || snapshot[1].Phase != PhaseFailed || snapshot[1].LastError != "Not OK" {
+		t.Errorf("unexpected phases after shutdown: %+v", snapshot)
+	}
+}
+
+func TestContextProgramShutdownDeadline(t *testing.T) {
+	deadlines := make(chan time.Duration, 1)
+	program := func(ctx context.Context, Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) error {
+		Flap <- StartupResult{Code: 200}
+		<-ctx.Done()
+		shutdownCtx, cancel := DaemonShutdownContext(ctx)
+		defer cancel()
+		deadline, _ := shutdownCtx.Deadline()
+		deadlines <- time.Until(deadline)
+		return shutdownCtx.Err()
+	}
+	manager := &Manager{
+		Daemons: []*Daemon{
+			{Name: "Context", ContextProgram: program, StartupGrace: time.Second, ShutdownGrace: time.Minute},
+		},
+	}
+
+	if err := manager.DaemonStartUp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	manager.DaemonShutDown()
+
+	select {
+	case remaining := <-deadlines:
+		if remaining <= 50*time.Second || remaining > time.Minute {
+			t.Errorf("expected shutdown deadline about a minute away, got %v", remaining)
+		}
+	default:
+		t.Fatalf("program did not observe the cancellation")
+	}
+}
+
+func TestDaemonHealthCheckDegrades(t *testing.T) {
+	healthy := make(chan error, 1)
+	healthy <- nil
+	check := func(ctx context.Context) error {
+		err := <-healthy
+		healthy <- err
+		return err
+	}
+	daemon := &Daemon{
+		Name: "Checked", StartupGrace: time.Second, ShutdownGrace: time.Second,
+		Program: func(Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) error {
+			Flap <- StartupResult{Code: 200}
+			<-Clap
+			return nil
+		},
+		HealthCheck: check, HealthInterval: 5 * time.Millisecond,
+	}
+	manager := &Manager{Daemons: []*Daemon{daemon}}
+	waitForPhase := func(phase DaemonPhase) DaemonSnapshot {
+		deadline := time.Now().Add(2 * time.Second)
+		for {
+			snapshot := manager.Snapshot()[0]
+			if snapshot.Phase == phase {
+				return snapshot
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("daemon did not become %s, got %+v", phase, snapshot)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	if err := manager.DaemonStartUp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitForPhase(PhaseRunning)
+
+	<-healthy
+	healthy <- errors.New("backend unreachable")
+	if snapshot := waitForPhase(PhaseDegraded); snapshot.HealthNote != "backend unreachable" {
+		t.Errorf("unexpected health note %q", snapshot.HealthNote)
+	}
+
+	<-healthy
+	healthy <- nil
+	waitForPhase(PhaseRunning)
+
+	manager.DaemonShutDown()
+	waitForPhase(PhaseStopped)
+}
+
+func TestAddAndRemoveDaemonAtRuntime(t *testing.T) {
+	waiting := func(Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) error {
+		Flap <- StartupResult{Code: 200}
+		<-Clap
+		return nil
+	}
+	cache := &Daemon{Name: "Cache", Program: waiting, StartupGrace: time.Second, ShutdownGrace: time.Second}
+	manager := &Manager{Daemons: []*Daemon{cache}}
+	if err := manager.DaemonStartUp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	warmer := &Daemon{Name: "Warmer", Program: waiting, DependsOn: []string{"Cache"}, StartupGrace: time.Second, ShutdownGrace: time.Second}
+	if err := manager.AddDaemon(warmer); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if warmer.Snapshot().Phase != PhaseRunning {
+		t.Errorf("added daemon should be running, got %s", warmer.Snapshot().Phase)
+	}
+	if err := manager.AddDaemon(&Daemon{Name: "Warmer", Program: waiting}); err == nil {
+		t.Errorf("expected an error for a name already registered")
+	}
+	if err := manager.AddDaemon(&Daemon{Name: "Poller", Program: waiting, DependsOn: []string{"Geocoder"}}); err == nil {
+		t.Errorf("expected an error for an unknown dependency")
+	}
+	broken := &Daemon{Name: "Broken", StartupGrace: time.Second, Program: func(Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) error {
+		Flap <- StartupResult{Code: 500, Note: "no upstream"}
+		return nil
+	}}
+	if err := manager.AddDaemon(broken); err == nil || !strings.Contains(err.Error(), "no upstream") {
+		t.Errorf("expected the startup failure, got %v", err)
+	}
+	if manager.lookup("Broken") != nil {
+		t.Errorf("daemon that failed to start should not stay registered")
+	}
+
+	// Concurrent registrations and status reads don't race (go test -race)
+	done := make(chan error, 4)
+	for _, name := range []string{"Poller1", "Poller2", "Poller3", "Poller4"} {
+		go func() {
+			manager.Snapshot()
+			done <- manager.AddDaemon(&Daemon{Name: name, Program: waiting, StartupGrace: time.Second, ShutdownGrace: time.Second})
+		}()
+	}
+	for range 4 {
+		if err := <-done; err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if len(manager.Snapshot()) != 6 {
+		t.Errorf("expected 6 registered daemons, got %d", len(manager.Snapshot()))
+	}
+
+	if err := manager.RemoveDaemon("Cache"); err == nil || !strings.Contains(err.Error(), "needed by daemon Warmer") {
+		t.Errorf("expected removal of a dependency to be refused, got %v", err)
+	}
+	if err := manager.RemoveDaemon("Warmer"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if warmer.Active() || manager.lookup("Warmer") != nil {
+		t.Errorf("removed daemon should be stopped and unregistered")
+	}
+
+	manager.DaemonShutDown()
+	if err := manager.AddDaemon(&Daemon{Name: "Late", Program: waiting}); err == nil {
+		t.Errorf("expected registration to be refused once the shutdown has begun")
+	}
+	for _, daemon := range manager.daemons() {
+		if daemon.Active() {
+			t.Errorf("daemon %s still running after shutdown", daemon.Name)
+		}
+	}
+}
+
+func TestDaemonShutDownReport(t *testing.T) {
+	waiting := func(err error) DaemonProgram {
+		return func(Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) error {
+			Flap <- StartupResult{Code: 200}
+			<-Clap
+			return err
+		}
+	}
+	release := make(chan struct{})
+	defer close(release)
+	clean := &Daemon{Name: "Clean", Program: waiting(nil), StartupGrace: time.Second, ShutdownGrace: time.Second}
+	manager := &Manager{Daemons: []*Daemon{
+		clean,
+		{Name: "Erroring", Program: waiting(errors.New("flush failed")), StartupGrace: time.Second, ShutdownGrace: time.Second},
+		{Name: "Hanging", StartupGrace: time.Second, ShutdownGrace: 50 * time.Millisecond, Program: func(Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) error {
+			Flap <- StartupResult{Code: 200}
+			<-Clap
+			<-release
+			return nil
+		}},
+		{Name: "Broken", StartupGrace: time.Second, Program: func(Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) error {
+			Flap <- StartupResult{Code: 500, Note: "no upstream"}
+			return nil
+		}},
+		{Name: "Idle"},
+	}, DumpDir: t.TempDir()}
+	if err := manager.DaemonStartUp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	report := manager.DaemonShutDown()
+	results := []string{}
+	for _, daemon := range report.Daemons {
+		results = append(results, daemon.Name+"="+string(daemon.Result))
+	}
+	if strings.Join(results, ",") != "Idle=not running,Broken=failed,Hanging=grace exceeded,Erroring=error,Clean=clean" {
+		t.Errorf("unexpected report %v", results)
+	}
+	if report.Daemons[3].Note != "flush failed" {
+		t.Errorf("expected the error to be reported, got %q", report.Daemons[3].Note)
+	}
+	if !report.Failed() {
+		t.Errorf("report with failures should be failed")
+	}
+	if clean.Active() {
+		t.Errorf("daemons after a failing one should still be stopped")
+	}
+	if (ShutdownReport{Daemons: []DaemonShutdown{{Result: ShutdownClean}, {Result: ShutdownNotRunning}}}).Failed() {
+		t.Errorf("clean report should not be failed")
+	}
+}
+
+func TestCriticalDaemonExitShutsDown(t *testing.T) {
+	exiting := func(exit chan error) DaemonProgram {
+		return func(Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) error {
+			Flap <- StartupResult{Code: 200}
+			select {
+			case err := <-exit:
+				return err
+			case <-Clap:
+				return nil
+			}
+		}
+	}
+	workerExit, listenerExit := make(chan error), make(chan error)
+	worker := &Daemon{Name: "Worker", Program: exiting(workerExit), StartupGrace: time.Second, ShutdownGrace: time.Second}
+	listener := &Daemon{Name: "Listener", Program: exiting(listenerExit), StartupGrace: time.Second, ShutdownGrace: time.Second, Critical: true}
+	manager := &Manager{Daemons: []*Daemon{worker, listener}}
+	if err := manager.DaemonStartUp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	workerExit <- errors.New("queue closed")
+	select {
+	case <-manager.ShutdownRequested():
+		t.Fatalf("exit of a non-critical daemon should not shut the process down")
+	case <-time.After(100 * time.Millisecond):
+	}
+	listenerExit <- errors.New("interface listener unexpectedly shutdown")
+	select {
+	case <-manager.ShutdownRequested():
+	case <-time.After(time.Second):
+		t.Fatalf("exit of a critical daemon should shut the process down")
+	}
+	manager.DaemonShutDown()
+}
+
+func TestCriticalDaemonStartupFailureShutsDown(t *testing.T) {
+	manager := &Manager{Daemons: []*Daemon{
+		{Name: "DHI0", Critical: true, StartupGrace: time.Second, Program: func(Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) error {
+			Flap <- StartupResult{Code: 500, Note: "address in use"}
+			return nil
+		}},
+	}}
+	if err := manager.DaemonStartUp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case <-manager.ShutdownRequested():
+	default:
+		t.Errorf("critical daemon that failed to start should shut the process down")
+	}
+	manager.DaemonShutDown()
+}
+
+func TestDiagnosticSignalKeepsRunning(t *testing.T) {
+	handled := make(chan struct{}, 1)
+	manager := &Manager{
+		SignalCh:         make(chan os.Signal, 1),
+		ShutdownSignal:   []syscall.Signal{syscall.SIGTERM},
+		DiagnosticSignal: map[syscall.Signal]func(){syscall.SIGUSR1: func() { handled <- struct{}{} }},
+	}
+	go manager.Supervise(manager.SignalCh)
+
+	manager.SignalCh <- syscall.SIGUSR1
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		t.Fatalf("diagnostic handler not run")
+	}
+	select {
+	case <-manager.ShutdownRequested():
+		t.Fatalf("diagnostic signal should not shut the process down")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	manager.SignalCh <- syscall.SIGTERM
+	select {
+	case <-manager.ShutdownRequested():
+	case <-time.After(time.Second):
+		t.Fatalf("shutdown signal still expected to shut the process down")
+	}
+}
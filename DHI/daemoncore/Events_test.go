@@ -0,0 +1,146 @@
+package daemoncore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventsLifecycle(t *testing.T) {
+	crash := make(chan struct{})
+	runs := 0
+	program := func(Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) error {
+		runs++
+		Flap <- StartupResult{Code: 200}
+		if runs == 1 {
+			<-crash
+			panic("boom")
+		}
+		<-Clap
+		return nil
+	}
+	daemon := &Daemon{
+		Name: "Worker", Program: program, StartupGrace: time.Second, ShutdownGrace: time.Second,
+		Restart: RestartPolicy{When: RestartOnFailure, Backoff: time.Millisecond},
+	}
+	manager := &Manager{Daemons: []*Daemon{daemon}}
+	events, cancel := manager.Subscribe(32)
+
+	if err := manager.DaemonStartUp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	kinds := []string{}
+	next := func() Event {
+		select {
+		case event := <-events:
+			kinds = append(kinds, string(event.Kind))
+			return event
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no event after %v", kinds)
+			return Event{}
+		}
+	}
+	next()
+	next()
+	close(crash)
+	for next().Kind != EventRunning {
+	}
+	manager.DaemonShutDown()
+	next()
+	last := next()
+	cancel()
+
+	expected := "started,running,panicked,restarting,started,running,stopping,stopped"
+	if strings.Join(kinds, ",") != expected {
+		t.Errorf("expected events %s, got %v", expected, kinds)
+	}
+	if last.Daemon != "Worker" || last.Details["code"] != 200 || last.Time.IsZero() {
+		t.Errorf("unexpected stopped event %+v", last)
+	}
+	if _, open := <-events; open {
+		t.Errorf("cancel should close the subscription")
+	}
+}
+
+// Journal writer recording how many lines were written when it was synced
+type syncedBuffer struct {
+	bytes.Buffer
+	synced []int
+}
+
+func (b *syncedBuffer) Sync() error {
+	b.synced = append(b.synced, bytes.Count(b.Bytes(), []byte("\n")))
+	return nil
+}
+
+func TestJournalKeepsEveryEvent(t *testing.T) {
+	manager := &Manager{}
+	journal := &syncedBuffer{}
+	end := manager.Journal(journal)
+	for i := 0; i < 5000; i++ {
+		manager.publish(EventRestarting, "Worker", "restarts", i, "delay", time.Second)
+	}
+	manager.publish(EventStopped, "Worker", "code", 200)
+	end()
+	manager.publish(EventStarted, "Worker")
+
+	lines := strings.Split(strings.TrimSpace(journal.String()), "\n")
+	if len(lines) != 5001 {
+		t.Fatalf("expected every event up to the end of the journal, got %d lines", len(lines))
+	}
+	if !strings.Contains(lines[4999], `"restarts":4999`) || !strings.Contains(lines[0], `"delay":"1s"`) {
+		t.Errorf("unexpected journal lines %s, %s", lines[0], lines[4999])
+	}
+	if len(journal.synced) != 1 || journal.synced[0] != 5001 {
+		t.Errorf("expected one sync after the stopped event, got %v", journal.synced)
+	}
+}
+
+func TestRunWritesJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lytup.journal")
+	failing := &Daemon{Name: "Failing", Program: func(Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) error {
+		Flap <- StartupResult{Code: 500, Note: "address in use"}
+		return nil
+	}}
+	worker := &Daemon{Name: "Worker", Program: func(Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) error {
+		Flap <- StartupResult{Code: 200}
+		<-Clap
+		return nil
+	}}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	Run(ctx, []*Daemon{worker, failing},
+		WithShutdownSignals(), WithReloadSignals(), WithGrace(time.Second, time.Second), WithJournal(path, 0, 0))
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("journal not written: %v", err)
+	}
+	defer file.Close()
+	// The failing program's own exit races the startup handshake, so lines are checked per daemon
+	lines := map[string][]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("journal line %q is not an event: %v", scanner.Text(), err)
+		}
+		line := string(event.Kind)
+		if event.Kind == EventStartupFailed {
+			line += " " + event.Details["note"].(string)
+		}
+		lines[event.Daemon] = append(lines[event.Daemon], line)
+	}
+	if got := strings.Join(lines["Worker"], ","); got != "started,running,stopping,stopped" {
+		t.Errorf("unexpected journal of Worker: %s", got)
+	}
+	if got := strings.Join(lines["Failing"], ","); !strings.HasPrefix(got, "started,") || !strings.Contains(got, "startup-failed address in use") {
+		t.Errorf("unexpected journal of Failing: %s", got)
+	}
+}
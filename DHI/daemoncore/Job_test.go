@@ -0,0 +1,141 @@
+package daemoncore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	from := time.Date(2026, time.March, 14, 10, 7, 30, 0, time.UTC) // Saturday
+	for expr, expected := range map[string]string{
+		"*/15 * * * *":    "2026-03-14 10:15",
+		"0 3 * * *":       "2026-03-15 03:00",
+		"30 9 * * 1-5":    "2026-03-16 09:30",
+		"0 0 1,15 * *":    "2026-03-15 00:00",
+		"0 12 * * 7":      "2026-03-15 12:00",
+		"0 0 13 * 1":      "2026-03-16 00:00", // day-of-month or day-of-week
+		"@monthly":        "2026-04-01 00:00",
+		"5-10/5 10 * * *": "2026-03-14 10:10",
+	} {
+		schedule, err := parseCron(expr)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", expr, err)
+			continue
+		}
+		if next := schedule.next(from).Format("2006-01-02 15:04"); next != expected {
+			t.Errorf("%s: expected %s, got %s", expr, expected, next)
+		}
+	}
+	for _, expr := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "x * * * *"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("%s: expected an error", expr)
+		}
+	}
+}
+
+func TestJobDaemonRunsAndStops(t *testing.T) {
+	var runs atomic.Int32
+	stopped := make(chan struct{})
+	daemon, err := NewJobDaemon("Tick", Job{Every: 20 * time.Millisecond, Run: func(ctx context.Context) error {
+		if runs.Add(1) == 2 {
+			return errors.New("upstream down")
+		}
+		if runs.Load() == 4 {
+			<-ctx.Done()
+			close(stopped)
+		}
+		return nil
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	logger, _, stderr := newTestLogger(t, LoggConfig{Level: "info", Format: "text", TimeZone: "UTC"})
+	manager := &Manager{Daemons: []*Daemon{daemon}, Logger: logger}
+	if err := manager.DaemonStartUp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runs.Load() < 4 {
+		if time.Now().After(deadline) {
+			t.Fatalf("job ran %d time(s), expected 4", runs.Load())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if note := daemon.Snapshot().LastError; note != "upstream down" {
+		t.Errorf("failed run should be recorded, got %q", note)
+	}
+	// The fourth run is still in progress: later runs are skipped
+	time.Sleep(60 * time.Millisecond)
+	if runs.Load() != 4 {
+		t.Errorf("runs should not overlap, got %d", runs.Load())
+	}
+	status, err := manager.DaemonCommand("Tick", CommandStatus, time.Second)
+	if err != nil || !strings.Contains(status.Note, "4 run(s), 1 failed, 1 running") {
+		t.Errorf("unexpected status %+v (%v)", status, err)
+	}
+
+	manager.DaemonShutDown()
+	select {
+	case <-stopped:
+	default:
+		t.Errorf("run in progress should be cancelled and waited for")
+	}
+	if daemon.Active() {
+		t.Errorf("job daemon still running after shutdown")
+	}
+	if !strings.Contains(stderr.String(), "Job Tick: Run failed [upstream down]") {
+		t.Errorf("failed run should be logged through the manager's logger, got %q", stderr.String())
+	}
+}
+
+func TestJobOverlapQueue(t *testing.T) {
+	var runs atomic.Int32
+	release := make(chan struct{})
+	daemon, err := NewJobDaemon("Queue", Job{Every: 10 * time.Millisecond, Overlap: OverlapQueue, Run: func(ctx context.Context) error {
+		if runs.Add(1) == 1 {
+			<-release
+		}
+		return nil
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	manager := &Manager{Daemons: []*Daemon{daemon}}
+	if err := manager.DaemonStartUp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer manager.DaemonShutDown()
+
+	time.Sleep(100 * time.Millisecond)
+	if runs.Load() != 1 {
+		t.Fatalf("expected 1 run while the first is blocked, got %d", runs.Load())
+	}
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for runs.Load() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("queued run did not start once the first finished")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNewJobDaemonRejectsInvalidJobs(t *testing.T) {
+	run := func(ctx context.Context) error { return nil }
+	for name, job := range map[string]Job{
+		"no schedule": {Run: run},
+		"no run":      {Every: time.Second},
+		"bad cron":    {Cron: "* * *", Run: run},
+		"never":       {Cron: "0 0 30 2 *", Run: run},
+		"bad overlap": {Every: time.Second, Overlap: "sometimes", Run: run},
+	} {
+		if _, err := NewJobDaemon(name, job); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
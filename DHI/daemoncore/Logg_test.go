@@ -0,0 +1,144 @@
+package daemoncore
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLogger(t *testing.T, conf LoggConfig) (*Logger, *bytes.Buffer, *bytes.Buffer) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	logger := &Logger{level: LevelInfo, location: time.UTC, stdout: stdout, stderr: stderr}
+	if err := logger.Configure(conf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return logger, stdout, stderr
+}
+
+func TestLoggerSourceLevels(t *testing.T) {
+	logger, stdout, stderr := newTestLogger(t, LoggConfig{
+		Level: "warn", Format: "text", TimeZone: "Africa/Lagos",
+		Sources: map[string]string{"Weather": "debug", "Cache": ""},
+	})
+
+	logger.Debug("Weather", "Cache hit", "city", "Lagos")
+	logger.Info("Cache", "Saved")
+	logger.Error("Cache", "Failed", "error", os.ErrNotExist)
+
+	if line := stdout.String(); !strings.Contains(line, "+01:00//Weather] DEBUG Cache hit city=Lagos") {
+		t.Errorf("unexpected stdout %q", line)
+	}
+	if strings.Contains(stdout.String(), "Saved") {
+		t.Errorf("info line below the default level was written")
+	}
+	if line := stderr.String(); !strings.Contains(line, `//Cache] ERROR Failed error="file does not exist"`) {
+		t.Errorf("unexpected stderr %q", line)
+	}
+}
+
+func TestLoggerToggleDebug(t *testing.T) {
+	logger, stdout, _ := newTestLogger(t, LoggConfig{
+		Level: "info", Format: "text", TimeZone: "UTC", Sources: map[string]string{"Cache": "warn"},
+	})
+
+	if !logger.ToggleDebug() {
+		t.Fatalf("first toggle should turn debug on")
+	}
+	logger.Debug("Cache", "Expired", "entries", 3)
+	if !strings.Contains(stdout.String(), "DEBUG Expired entries=3") {
+		t.Errorf("debug line of a source with its own level not written while toggled on: %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if logger.ToggleDebug() {
+		t.Fatalf("second toggle should turn debug off")
+	}
+	logger.Debug("Weather", "Cache hit")
+	logger.Info("Cache", "Saved")
+	if stdout.Len() != 0 {
+		t.Errorf("levels not restored after toggling off: %q", stdout.String())
+	}
+}
+
+func TestLoggerJSON(t *testing.T) {
+	logger, stdout, _ := newTestLogger(t, LoggConfig{Level: "info", Format: "json", TimeZone: "UTC"})
+
+	logger.Info("Cache", "Loaded", "entries", 3, "file", "weather_cache.json")
+
+	var line map[string]any
+	if err := json.Unmarshal(stdout.Bytes(), &line); err != nil {
+		t.Fatalf("line is not JSON: %v (%q)", err, stdout.String())
+	}
+	if line["level"] != "info" || line["source"] != "Cache" || line["msg"] != "Loaded" || line["entries"] != 3.0 {
+		t.Errorf("unexpected line %v", line)
+	}
+}
+
+func TestLoggerConfigureRejectsUnknownZone(t *testing.T) {
+	logger := &Logger{level: LevelInfo, location: time.UTC, stdout: os.Stdout, stderr: os.Stderr}
+	err := logger.Configure(LoggConfig{Level: "info", Format: "text", TimeZone: "Mars/Olympus_Mons"})
+	if err == nil || !strings.Contains(err.Error(), "logging.time_zone") {
+		t.Errorf("expected time zone error, got %v", err)
+	}
+	if logger.location != time.UTC {
+		t.Errorf("failed configuration should leave the logger unchanged")
+	}
+}
+
+func TestRotatingFileRetention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lytup.log")
+	file, err := OpenRotatingFile(path, 10, 2, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer file.Close()
+
+	for i := 0; i < 5; i++ {
+		if _, err := file.Write([]byte("0123456789")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	backups, _ := filepath.Glob(path + ".*")
+	if len(backups) != 2 {
+		t.Errorf("expected 2 rotated files, got %v", backups)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "0123456789" {
+		t.Errorf("unexpected current file %q", data)
+	}
+}
+
+func TestRotatingFileSurvivesFailedRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lytup.log")
+	file, err := OpenRotatingFile(path, 10, 0, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer file.Close()
+	errs := &bytes.Buffer{}
+	file.Errors = errs
+
+	// The rename fails once the file is gone
+	file.Write([]byte("0123456789"))
+	os.Remove(path)
+	for i := 0; i < 3; i++ {
+		if _, err := file.Write([]byte("abcdefghij")); err != nil {
+			t.Fatalf("write after a failed rotation: %v", err)
+		}
+		os.Remove(path)
+	}
+	if strings.Count(errs.String(), "not rotated") != 1 {
+		t.Errorf("expected the failed rotation to be reported once, got %q", errs.String())
+	}
+
+	// Rotation works again once the file is back
+	file.Write([]byte("0123456789"))
+	file.Write([]byte("klmnopqrst"))
+	if data, _ := os.ReadFile(path); string(data) != "klmnopqrst" {
+		t.Errorf("unexpected current file %q", data)
+	}
+}
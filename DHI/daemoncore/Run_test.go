@@ -0,0 +1,96 @@
+package daemoncore
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunUntilContextDone(t *testing.T) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	logger := &Logger{level: LevelInfo, location: time.UTC, stdout: stdout, stderr: stderr}
+	worker := &Daemon{Name: "Worker", Program: func(Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) error {
+		Flap <- StartupResult{Code: 200}
+		<-Clap
+		return nil
+	}}
+	hooked := false
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	code := Run(ctx, []*Daemon{worker},
+		WithShutdownSignals(), WithReloadSignals(), WithLogger(logger),
+		WithGrace(time.Second, 2*time.Second),
+		WithShutdownHook(func() { hooked = worker.Active() }),
+	)
+	if code != ExitClean {
+		t.Errorf("expected a clean exit, got %d", code)
+	}
+	if !hooked {
+		t.Errorf("shutdown hook should run before the daemons are stopped")
+	}
+	if worker.StartupGrace != time.Second || worker.ShutdownGrace != 2*time.Second {
+		t.Errorf("grace periods not applied: %v, %v", worker.StartupGrace, worker.ShutdownGrace)
+	}
+	if !strings.Contains(stdout.String(), "Shutdown requested [Context done [context canceled]]") ||
+		!strings.Contains(stdout.String(), "Shutdown complete (exit code 0)") {
+		t.Errorf("manager lines not written to its logger: %q", stdout.String())
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	failing := &Daemon{Name: "Failing", StartupGrace: time.Second, Program: func(Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) error {
+		Flap <- StartupResult{Code: 500, Note: "address in use"}
+		return nil
+	}}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if code := Run(ctx, []*Daemon{failing}, WithShutdownSignals(), WithReloadSignals(), WithExitCodes(70, 71)); code != 70 {
+		t.Errorf("expected the startup failure code, got %d", code)
+	}
+
+	// A daemon that started successfully and returned since is not a startup failure
+	once := &Daemon{Name: "Once", StartupGrace: time.Second, Program: func(Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) error {
+		Flap <- StartupResult{Code: 200}
+		return nil
+	}}
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if code := Run(ctx, []*Daemon{once}, WithShutdownSignals(), WithReloadSignals()); code != ExitClean {
+		t.Errorf("expected a clean exit for a daemon that started, got %d", code)
+	}
+
+	cycle := []*Daemon{{Name: "A", DependsOn: []string{"B"}}, {Name: "B", DependsOn: []string{"A"}}}
+	if code := Run(context.Background(), cycle, WithShutdownSignals(), WithReloadSignals()); code != ExitStartupFailed {
+		t.Errorf("expected the default startup failure code, got %d", code)
+	}
+}
+
+func TestRunReleasesSignals(t *testing.T) {
+	manager := New(nil, WithShutdownSignals(syscall.SIGUSR1), WithReloadSignals())
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	manager.Run(ctx)
+
+	// Another listener keeps SIGUSR1 from terminating the test binary
+	other := make(chan os.Signal, 1)
+	signal.Notify(other, syscall.SIGUSR1)
+	defer signal.Stop(other)
+	syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+	select {
+	case <-other:
+	case <-time.After(time.Second):
+		t.Fatalf("signal not delivered")
+	}
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case sig := <-manager.SignalCh:
+		t.Errorf("signals should be released once Run returns, got %v", sig)
+	default:
+	}
+}
@@ -0,0 +1,183 @@
+package daemoncore
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func listenNotifySocket(t *testing.T) *net.UnixConn {
+	dir, err := os.MkdirTemp("", "lytup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+	return conn
+}
+
+// Reads notifications until one containing want arrives
+func expectNotification(t *testing.T, conn *net.UnixConn, want string) {
+	t.Helper()
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("no %s notification: %v", want, err)
+		}
+		if strings.Contains(string(buf[:n]), want) {
+			return
+		}
+	}
+}
+
+func TestSystemdNotifyLifecycle(t *testing.T) {
+	conn := listenNotifySocket(t)
+	t.Setenv("WATCHDOG_USEC", "20000")
+	t.Setenv("WATCHDOG_PID", "")
+
+	healthy := make(chan error, 1)
+	healthy <- nil
+	daemon := &Daemon{
+		Name: "DHI0", StartupGrace: time.Second, ShutdownGrace: time.Second,
+		Program: func(Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) error {
+			Flap <- StartupResult{Code: 200}
+			<-Clap
+			return nil
+		},
+		HealthCheck: func(ctx context.Context) error {
+			err := <-healthy
+			healthy <- err
+			return err
+		},
+		HealthInterval: 5 * time.Millisecond,
+	}
+	manager := &Manager{Daemons: []*Daemon{daemon}}
+
+	if err := manager.DaemonStartUp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectNotification(t, conn, "READY=1")
+	expectNotification(t, conn, "WATCHDOG=1")
+
+	// No pings while degraded
+	<-healthy
+	healthy <- errors.New("backend unreachable")
+	for daemon.Snapshot().Phase != PhaseDegraded {
+		time.Sleep(5 * time.Millisecond)
+	}
+	drainUntil := time.Now().Add(30 * time.Millisecond)
+	buf := make([]byte, 1024)
+	for conn.SetReadDeadline(drainUntil); ; {
+		if _, err := conn.Read(buf); err != nil {
+			break
+		}
+	}
+	conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if n, err := conn.Read(buf); err == nil {
+		t.Errorf("expected no notification while degraded, got %q", buf[:n])
+	}
+
+	manager.DaemonShutDown()
+	expectNotification(t, conn, "STOPPING=1")
+}
+
+func TestHealthyIgnoresDaemonsGivenUp(t *testing.T) {
+	fail := func(Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) error {
+		Flap <- StartupResult{Code: 200}
+		return errors.New("failed for good")
+	}
+	optional := &Daemon{Name: "Optional", Program: fail, StartupGrace: time.Second, ShutdownGrace: time.Second}
+	retrying := &Daemon{
+		Name: "Retrying", Program: fail, StartupGrace: time.Second, ShutdownGrace: time.Second,
+		Restart: RestartPolicy{When: RestartOnFailure, Backoff: time.Hour},
+	}
+	manager := &Manager{Daemons: []*Daemon{optional, retrying}}
+	if err := manager.DaemonStartUp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer manager.DaemonShutDown()
+	time.Sleep(100 * time.Millisecond)
+
+	if manager.Healthy() {
+		t.Errorf("a daemon waiting for its restart should be unhealthy")
+	}
+	manager.DaemonStop(retrying)
+	if !manager.Healthy() {
+		t.Errorf("failed daemons that won't be restarted should not count: %+v", manager.Snapshot())
+	}
+	optional.Critical = true
+	if manager.Healthy() {
+		t.Errorf("a failed critical daemon should be unhealthy")
+	}
+}
+
+func TestSystemdReadyWithheldOnFailedStartup(t *testing.T) {
+	conn := listenNotifySocket(t)
+	manager := &Manager{Daemons: []*Daemon{{
+		Name: "DHI0", StartupGrace: time.Second,
+		Program: func(Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) error {
+			Flap <- StartupResult{Code: 500, Note: "port in use"}
+			return errors.New("port in use")
+		},
+	}}}
+
+	if err := manager.DaemonStartUp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != "STATUS=Daemon DHI0 is failed" {
+		t.Errorf("expected a status instead of READY=1, got %q, %v", buf[:n], err)
+	}
+	manager.DaemonShutDown()
+}
+
+func TestSystemdListenFDs(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	file, err := listener.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+	t.Setenv("LISTEN_FDNAMES", "https")
+
+	// systemdListenFDs takes ownership of the descriptor, as it would of one passed by systemd
+	fd, err := syscall.Dup(int(file.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	listeners, err := systemdListenFDs(fd)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	activated, ok := listeners["https"]
+	if !ok || activated.Addr().String() != listener.Addr().String() {
+		t.Fatalf("expected the socket under its name, got %v", listeners)
+	}
+	activated.Close()
+	if os.Getenv("LISTEN_FDS") != "" {
+		t.Errorf("LISTEN_* variables should be removed from the environment")
+	}
+}
@@ -0,0 +1,108 @@
+package daemoncore
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestUpgradeHandsOverListeners(t *testing.T) {
+	// New process: serve the inherited listener and report ready
+	if os.Getenv(upgradeFDNamesEnv) != "" {
+		listeners, err := InheritedListeners()
+		if err != nil || listeners["http"] == nil {
+			os.Exit(1)
+		}
+		go http.Serve(listeners["http"], http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "new")
+		}))
+		(&Manager{}).upgradeNotify("READY=1")
+		time.Sleep(2 * time.Second)
+		os.Exit(0)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	// The old server gets its own listener on the socket, so closing it leaves the socket open
+	file, err := listener.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	runListener, err := net.FileListener(file)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "old")
+	})}
+	go server.Serve(runListener)
+
+	// The new process is this test binary, running only this test
+	args := os.Args
+	os.Args = []string{args[0], "-test.run=^TestUpgradeHandsOverListeners$"}
+	defer func() { os.Args = args }()
+	saved := false
+	manager := &Manager{Handoff: func() (map[string]net.Listener, error) {
+		saved = true
+		return map[string]net.Listener{"http": listener}, nil
+	}}
+
+	if err := manager.Upgrade(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !saved {
+		t.Errorf("Handoff was not called")
+	}
+	select {
+	case <-manager.ShutdownRequested():
+	default:
+		t.Errorf("upgraded process should be asked to shut down")
+	}
+	if !manager.Upgraded() {
+		t.Errorf("Upgraded should report the handover")
+	}
+
+	// Once the old server is gone, the same socket is served by the new process
+	server.Close()
+	resp, err := http.Get("http://" + listener.Addr().String() + "/")
+	if err != nil {
+		t.Fatalf("socket not served after upgrade: %v", err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "new" {
+		t.Errorf("expected the new process to answer, got %q", body)
+	}
+}
+
+func TestUpgradeFailsWhenNewProcessExits(t *testing.T) {
+	// New process: exit without reporting
+	if os.Getenv(upgradeNotifyEnv) != "" {
+		os.Exit(1)
+	}
+
+	args := os.Args
+	os.Args = []string{args[0], "-test.run=^TestUpgradeFailsWhenNewProcessExits$"}
+	defer func() { os.Args = args }()
+	manager := &Manager{Handoff: func() (map[string]net.Listener, error) {
+		return map[string]net.Listener{}, nil
+	}}
+
+	if err := manager.Upgrade(); err == nil {
+		t.Fatalf("expected an error when the new process exits before it is ready")
+	}
+	select {
+	case <-manager.ShutdownRequested():
+		t.Errorf("failed upgrade should leave the process running")
+	default:
+	}
+	if manager.Upgraded() {
+		t.Errorf("failed upgrade should not be reported as a handover")
+	}
+}
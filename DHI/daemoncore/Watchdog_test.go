@@ -0,0 +1,210 @@
+package daemoncore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Program that hangs on its first run and sends heartbeats on every later run
+func hangingOnce(block chan struct{}) DaemonProgram {
+	var runs atomic.Int32
+	return func(Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) error {
+		run := runs.Add(1)
+		Flap <- StartupResult{Code: 200}
+		if run == 1 {
+			<-block
+			return nil
+		}
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-Clap:
+				return nil
+			case <-ticker.C:
+				select {
+				case Flap <- Heartbeat{}:
+				default:
+				}
+			}
+		}
+	}
+}
+
+func dumpFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "goroutines-*.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return files
+}
+
+func TestHungDaemonRestarted(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	dir := t.TempDir()
+	daemon := &Daemon{
+		Name: "Poller", Program: hangingOnce(block), StartupGrace: time.Second, ShutdownGrace: time.Second,
+		HeartbeatTimeout: 100 * time.Millisecond,
+	}
+	manager := &Manager{Daemons: []*Daemon{daemon}, DumpDir: dir}
+	if err := manager.DaemonStartUp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for daemon.Snapshot().Restarts == 0 || daemon.Snapshot().Phase != PhaseRunning {
+		if time.Now().After(deadline) {
+			t.Fatalf("hung daemon was not restarted: %+v", daemon.Snapshot())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// The new run beats, so it is left alone
+	time.Sleep(300 * time.Millisecond)
+	if snapshot := daemon.Snapshot(); snapshot.Restarts != 1 || snapshot.HeartbeatAt.IsZero() {
+		t.Errorf("expected one restart and a heartbeat, got %+v", snapshot)
+	}
+	files := dumpFiles(t, dir)
+	if len(files) != 1 {
+		t.Fatalf("expected one goroutine dump, got %v", files)
+	}
+	if data, _ := os.ReadFile(files[0]); !strings.Contains(string(data), "goroutine") {
+		t.Errorf("dump holds no goroutines: %q", data)
+	}
+
+	if report := manager.DaemonShutDown(); report.Failed() {
+		t.Errorf("restarted daemon should stop cleanly: %+v", report)
+	}
+}
+
+func TestHungDaemonTerminates(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	daemon := &Daemon{
+		Name: "Poller", Program: hangingOnce(block), StartupGrace: time.Second, ShutdownGrace: time.Second,
+		HeartbeatTimeout: 50 * time.Millisecond, HungPolicy: HungTerminate,
+	}
+	manager := &Manager{Daemons: []*Daemon{daemon}, DumpDir: t.TempDir()}
+	if err := manager.DaemonStartUp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case <-manager.ShutdownRequested():
+	case <-time.After(2 * time.Second):
+		t.Fatalf("hung daemon with the terminate policy should shut the process down")
+	}
+	manager.DaemonShutDown()
+	if snapshot := daemon.Snapshot(); snapshot.Phase != PhaseFailed || !strings.Contains(snapshot.LastError, "No heartbeat") {
+		t.Errorf("expected a failed daemon without heartbeat, got %+v", snapshot)
+	}
+}
+
+func TestHungDaemonRestartBudgetEscalates(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	hanging := func(Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) error {
+		Flap <- StartupResult{Code: 200}
+		<-block
+		return nil
+	}
+	daemon := &Daemon{
+		Name: "Poller", Program: hanging, StartupGrace: time.Second, ShutdownGrace: time.Second,
+		HeartbeatTimeout: 50 * time.Millisecond,
+		Restart:          RestartPolicy{MaxRestarts: 1, Window: time.Minute, Backoff: 20 * time.Millisecond, Escalate: true},
+	}
+	manager := &Manager{Daemons: []*Daemon{daemon}, DumpDir: t.TempDir()}
+	if err := manager.DaemonStartUp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case <-manager.ShutdownRequested():
+	case <-time.After(2 * time.Second):
+		t.Fatalf("a daemon hanging past its restart budget should shut the process down")
+	}
+	manager.DaemonShutDown()
+	if snapshot := daemon.Snapshot(); snapshot.Restarts != 1 {
+		t.Errorf("expected one restart within the budget, got %+v", snapshot)
+	}
+}
+
+func TestShutdownGraceExceededDumpsGoroutines(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	dir := t.TempDir()
+	daemon := &Daemon{Name: "Poller", Program: hangingOnce(block), StartupGrace: time.Second, ShutdownGrace: 50 * time.Millisecond}
+	manager := &Manager{Daemons: []*Daemon{daemon}, DumpDir: dir}
+	if err := manager.DaemonStartUp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	result := manager.DaemonStop(daemon)
+	if result.Result != ShutdownGraceExceeded || !strings.Contains(result.Note, "goroutines dumped to") {
+		t.Errorf("expected an exceeded grace period with a dump, got %+v", result)
+	}
+	if files := dumpFiles(t, dir); len(files) != 1 {
+		t.Errorf("expected one goroutine dump, got %v", files)
+	}
+
+	// The abandoned run doesn't keep the daemon from starting again
+	if !manager.DaemonStart(daemon) {
+		t.Fatalf("daemon should start again after its hung run was abandoned")
+	}
+	if result := manager.DaemonStop(daemon); result.Failed() {
+		t.Errorf("second run should stop cleanly: %+v", result)
+	}
+}
+
+func TestShutdownNotBlockedByUnreadCommand(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	daemon := &Daemon{Name: "Poller", Program: hangingOnce(block), StartupGrace: time.Second, ShutdownGrace: 50 * time.Millisecond}
+	manager := &Manager{Daemons: []*Daemon{daemon}, DumpDir: t.TempDir()}
+	if err := manager.DaemonStartUp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The status command is accepted but never read, so it stays in the clap
+	if _, err := manager.DaemonCommand("Poller", CommandStatus, 10*time.Millisecond); err == nil {
+		t.Fatalf("expected the status command to go unanswered")
+	}
+
+	stopped := make(chan DaemonShutdown, 1)
+	go func() { stopped <- manager.DaemonStop(daemon) }()
+	select {
+	case result := <-stopped:
+		if result.Result != ShutdownGraceExceeded {
+			t.Errorf("expected an exceeded grace period, got %+v", result)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("DaemonStop blocked on the unread command")
+	}
+}
+
+func TestHeartbeatSurvivesHealthChecks(t *testing.T) {
+	block := make(chan struct{})
+	close(block)
+	daemon := &Daemon{
+		Name: "Poller", Program: hangingOnce(block), StartupGrace: time.Second, ShutdownGrace: time.Second,
+		HeartbeatTimeout: 100 * time.Millisecond, HealthInterval: 5 * time.Millisecond,
+		HealthCheck: func(context.Context) error { return nil },
+		Restart:     RestartPolicy{When: RestartAlways},
+	}
+	manager := &Manager{Daemons: []*Daemon{daemon}, DumpDir: t.TempDir()}
+	if err := manager.DaemonStartUp(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer manager.DaemonShutDown()
+
+	// The first run returns at once and is restarted; the second one beats
+	time.Sleep(400 * time.Millisecond)
+	if snapshot := daemon.Snapshot(); snapshot.Restarts != 1 || snapshot.Phase != PhaseRunning {
+		t.Errorf("a daemon that beats should not be handled as hung, got %+v", snapshot)
+	}
+}
@@ -0,0 +1,7 @@
+package daemoncore
+
+import  "time"
+
+var     DaemonCommandTimeout time.Duration = time.Second * 10  // how long DaemonReload waits for each daemon's reply
+var     UpgradeTimeout time.Duration = time.Second * 60  // how long Upgrade waits for the new process to be ready
+var     HeartbeatInterval time.Duration = time.Second * 5  // how often running programs send a Heartbeat
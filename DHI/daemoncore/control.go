@@ -0,0 +1,226 @@
+package daemoncore
+
+import  "bytes"
+import  "encoding/json"
+import  "errors"
+import  "fmt"
+import  "net"
+import  "os"
+import  "runtime/pprof"
+import  "slices"
+import  "time"
+
+/* Admin control socket.
+ * A client connects to Manager.ControlSocket, writes one ControlRequest as JSON and reads one ControlResponse back.
+ * Actions: list, stop <daemon>, start <daemon>, reload [daemon], upgrade, goroutines (see cmd/lytupctl)
+*/
+type    ControlRequest struct {
+	Action  string  `json:"action"`
+	Daemon  string  `json:"daemon,omitempty"`
+}
+type    ControlResponse struct {
+	OK          bool                      `json:"ok"`
+	Error       string                    `json:"error,omitempty"`
+	Daemons     []ControlDaemon           `json:"daemons,omitempty"`     // list
+	Reloads     map[string]CommandResult  `json:"reloads,omitempty"`     // reload
+	Goroutines  string                    `json:"goroutines,omitempty"`  // goroutines
+}
+type    ControlDaemon struct {
+	DaemonSnapshot
+	Uptime  string  `json:"uptime,omitempty"`  // empty unless the daemon is running
+}
+
+/* Starts serving the control socket at m.ControlSocket until a shutdown is requested.
+ * A stale socket file left by a previous process is replaced; a socket another process is still serving is not.
+ * Returns an error if the socket can't be created
+*/
+func (m *Manager) ServeControl() error {
+	/***1***/
+	if m.ControlSocket == "" {
+		return nil
+	}
+	if xb05, xb10 := net.DialTimeout("unix", m.ControlSocket, time.Second); xb10 == nil {
+		xb05.Close()
+		return fmt.Errorf(`control socket %s is served by another process`, m.ControlSocket)
+	}
+	os.Remove(m.ControlSocket)
+	xb15, xb20 := net.Listen("unix", m.ControlSocket)
+	if xb20 != nil {
+		return xb20
+	}
+	if xb25 := os.Chmod(m.ControlSocket, 0600); xb25 != nil {
+		xb15.Close()
+		return xb25
+	}
+	m.mutex.Lock()
+	m.control = xb15
+	m.mutex.Unlock()
+	m.logg("OUT", "Manager", fmt.Sprintf("Control socket listening [%s]", m.ControlSocket))
+
+	/***2***/
+	go func() {
+		<- m.ShutdownRequested()
+		m.closeControl()
+	}()
+	go func() {
+		for {
+			xc05, xc10 := xb15.Accept()
+			if xc10 != nil {
+				return
+			}
+			go m.controlConn(xc05)
+		}
+	}()
+	return nil
+}
+
+/* Stops serving the control socket and removes it. Connections already accepted are still answered
+*/
+func (m *Manager) closeControl() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.control != nil {
+		m.control.Close()
+		m.control = nil
+	}
+}
+
+func (m *Manager) controlConn(conn net.Conn) {
+	defer conn.Close()
+	var xb05 ControlRequest
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	if xb10 := json.NewDecoder(conn).Decode(&xb05); xb10 != nil {
+		json.NewEncoder(conn).Encode(ControlResponse{ Error: fmt.Sprintf(`invalid request [%s]`, xb10.Error()) })
+		return
+	}
+	m.logg("OUT", "Manager", fmt.Sprintf("Control request [%s %s]", xb05.Action, xb05.Daemon))
+	xb15 := m.Control(xb05)
+	if xb15.OK == false {
+		m.logg("ERR", "Manager", fmt.Sprintf("Control request failed [%s %s: %s]", xb05.Action, xb05.Daemon, xb15.Error))
+	}
+	json.NewEncoder(conn).Encode(xb15)
+}
+
+/* Carries out a control request
+*/
+func (m *Manager) Control(request ControlRequest) ControlResponse {
+	/***1***/
+	var xb05 *Daemon
+	if request.Daemon != "" {
+		xb05 = m.lookup(request.Daemon)
+		if xb05 == nil {
+			return ControlResponse{ Error: fmt.Sprintf(`daemon %s is not registered`, request.Daemon) }
+		}
+	}
+	if xb05 == nil && (request.Action == "stop" || request.Action == "start") {
+		return ControlResponse{ Error: fmt.Sprintf(`%s needs a daemon name`, request.Action) }
+	}
+
+	/***2***/
+	switch request.Action {
+	case "list":
+		xc05 := ControlResponse{ OK: true, Daemons: []ControlDaemon{} }
+		for _ , xd10 := range m.Snapshot() {
+			xd15 := ControlDaemon{ DaemonSnapshot: xd10 }
+			if xd10.Phase == PhaseRunning || xd10.Phase == PhaseDegraded {
+				xd15.Uptime = time.Since(xd10.StartedAt).Truncate(time.Second).String()
+			}
+			xc05.Daemons = append(xc05.Daemons, xd15)
+		}
+		return xc05
+	case "stop":
+		if xc05 := m.controlStop(xb05); xc05 != nil {
+			return ControlResponse{ Error: xc05.Error() }
+		}
+		return ControlResponse{ OK: true }
+	case "start":
+		if xc05 := m.controlStart(xb05); xc05 != nil {
+			return ControlResponse{ Error: xc05.Error() }
+		}
+		return ControlResponse{ OK: true }
+	case "reload":
+		xc05 := []string{}
+		if xb05 != nil {
+			xc05 = append(xc05, xb05.Name)
+			if xb05.Active() == false {
+				return ControlResponse{ Error: fmt.Sprintf(`daemon %s is not running`, xb05.Name) }
+			}
+		}
+		return ControlResponse{ OK: true, Reloads: m.DaemonReload(xc05...) }
+	case "upgrade":
+		if xc05 := m.Upgrade(); xc05 != nil {
+			return ControlResponse{ Error: xc05.Error() }
+		}
+		return ControlResponse{ OK: true }
+	case "goroutines":
+		xc05 := &bytes.Buffer{}
+		pprof.Lookup("goroutine").WriteTo(xc05, 2)
+		return ControlResponse{ OK: true, Goroutines: xc05.String() }
+	}
+	return ControlResponse{ Error: fmt.Sprintf(`unknown action %q (list, stop, start, reload, upgrade, goroutines)`, request.Action) }
+}
+
+/* Stops a daemon unless a running daemon depends on it
+*/
+func (m *Manager) controlStop(daemon *Daemon) error {
+	if daemon.Active() == false {
+		return fmt.Errorf(`daemon %s is not running`, daemon.Name)
+	}
+	for _ , xc10 := range m.daemons() {
+		if slices.Contains(xc10.DependsOn, daemon.Name) && xc10.Active() {
+			return fmt.Errorf(`daemon %s is needed by running daemon %s`, daemon.Name, xc10.Name)
+		}
+	}
+	if xb05 := m.DaemonStop(daemon); xb05.Failed() {
+		return fmt.Errorf(`daemon %s stopped with an error [%s %s]`, daemon.Name, xb05.Result, xb05.Note)
+	}
+	return nil
+}
+
+/* Starts a stopped daemon once its dependencies are running
+*/
+func (m *Manager) controlStart(daemon *Daemon) error {
+	/***1***/
+	select {
+	case <- m.ShutdownRequested():
+		return errors.New(`shutting down`)
+	default:
+	}
+	if daemon.Program == nil && daemon.ContextProgram == nil {
+		return fmt.Errorf(`daemon %s has no program to run`, daemon.Name)
+	}
+	if daemon.Active() {
+		return fmt.Errorf(`daemon %s is %s`, daemon.Name, daemon.Snapshot().Phase)
+	}
+	for _ , xc10 := range daemon.DependsOn {
+		xc15 := m.lookup(xc10)
+		if xc15 == nil || xc15.Snapshot().Phase != PhaseRunning && xc15.Snapshot().Phase != PhaseDegraded {
+			return fmt.Errorf(`dependency %s is not running`, xc10)
+		}
+	}
+
+	/***2***/
+	if m.DaemonStart(daemon) == false {
+		return fmt.Errorf(`daemon %s failed to start [%s]`, daemon.Name, daemon.Snapshot().LastError)
+	}
+	return nil
+}
+
+/* Sends a request to the control socket at path and returns the response
+*/
+func    ControlCall(path string, request ControlRequest, timeout time.Duration) (ControlResponse, error) {
+	xb05, xb10 := net.DialTimeout("unix", path, timeout)
+	if xb10 != nil {
+		return ControlResponse{}, xb10
+	}
+	defer xb05.Close()
+	xb05.SetDeadline(time.Now().Add(timeout))
+	if xb15 := json.NewEncoder(xb05).Encode(request); xb15 != nil {
+		return ControlResponse{}, xb15
+	}
+	var xb20 ControlResponse
+	if xb25 := json.NewDecoder(xb05).Decode(&xb20); xb25 != nil {
+		return ControlResponse{}, xb25
+	}
+	return xb20, nil
+}
@@ -0,0 +1,822 @@
+/* Package daemoncore runs a set of daemons in one process: dependency-ordered startup and reverse-ordered shutdown,
+ * restart policies, health checks, heartbeats, periodic jobs, signals, an admin control socket, systemd notification and zero-downtime upgrades.
+ * Run is the entry point of a binary; Manager can be created with New, or as a literal, and driven in-process
+*/
+package daemoncore
+
+/*
+	Name: DaemonCore-go
+	Version: 0.0.3
+*/
+
+import  "context"
+import  "fmt"
+import  "net"
+import  "os"
+import  "os/signal"
+import  "runtime/debug"
+import  "slices"
+import  "strings"
+import  "sync"
+import  "time"
+import  "syscall"
+
+
+/* Gracefully stops all running daemons. 
+ * Ensures reseources are released in a dependency-safe order by shutting down in the reverse of the startup order.
+ * A daemon that fails to stop doesn't keep the others from being stopped
+ * Returns the outcome for every registered daemon, in shutdown order
+*/
+func (m *Manager) DaemonShutDown() ShutdownReport {
+		m.systemdStopping()
+		xc05, xc06 := m.DaemonOrder()
+		if xc06 != nil {
+			xc05 = slices.Clone(m.daemons())
+		}
+		slices.Reverse(xc05)
+		xc10 := ShutdownReport{}
+		for _ , xd10 := range xc05 {
+			xc10.Daemons = append(xc10.Daemons, m.DaemonStop(xd10))
+		}
+		return xc10
+	} ;
+
+/* Stops a single daemon: asks its program to shut down and waits up to its ShutdownGrace for the execution outcome.
+ * The daemon is not restarted afterwards.
+ * Returns how the daemon stopped; a daemon that wasn't running is reported as stopped, or failed if its last run failed
+*/
+func (m *Manager) DaemonStop(daemon *Daemon) DaemonShutdown {
+	m.lifecycle.Lock()
+	defer m.lifecycle.Unlock()
+	xb01 := time.Now()
+
+	/***1***/
+	daemon.mutex.Lock()
+	if daemon.halt != nil && daemon.Halted() == false {
+		close(daemon.halt)
+	}
+	daemon.restarting = false
+	xb05 := daemon.clap
+	xb10 := daemon.exit
+	if daemon.context != nil {
+		daemon.context.stop(daemon.ShutdownGrace)
+	}
+	daemon.mutex.Unlock()
+	if daemon.Active() == false {
+		if xc05 := daemon.Snapshot(); xc05.Phase == PhaseFailed {
+			return DaemonShutdown{ Name: daemon.Name, Result: ShutdownFailed, Note: xc05.LastError }
+		}
+		return DaemonShutdown{ Name: daemon.Name, Result: ShutdownNotRunning }
+	}
+	daemon.setPhase(PhaseStopping, "")
+	m.publish(EventStopping, daemon.Name)
+
+	/***2***/
+	xb20 := make (chan bool, 1)
+	if daemon.ShutdownGrace != 0{
+		go func () {
+			time.Sleep(daemon.ShutdownGrace)
+			xb20 <- true
+		}  ( )
+	}
+	// A command the program never read holds the clap's only slot: it is dropped, and the send gives up with the grace period
+	select {
+		case <- xb05:
+		default:
+	}
+	select {
+		case xb05 <- DaemonCommand{ Kind: CommandShutdown }:
+			xb15 := fmt.Sprintf (
+				`PROJECT: Daemon %s: Shutdown signalledd`, daemon.Name,
+			)
+			m.logg ("OUT", "Main", xb15)
+		case _ = <- xb20:
+			xb20 <- true
+	}
+
+	xb25 := DaemonShutdown{ Name: daemon.Name, Result: ShutdownClean }
+	select  {
+		case xc05 := <- xb10: {
+			if xc05.Code != 200 {
+				xd05 := fmt.Sprintf (
+					`PROJECT: Daemon %s: Encountered error [%s]`, daemon.Name, xc05.Note,
+				)
+				m.logg ("ERR", "Main", xd05)
+				xb25.Result, xb25.Note = ShutdownError, xc05.Note
+			}
+		}
+		case _ = <- xb20: {
+			// The run is abandoned so the daemon can be started again
+			xb25.Result = ShutdownGraceExceeded
+			xb25.Note = m.daemonHung(daemon, fmt.Sprintf(`Shutdown grace period of %v exceeded`, daemon.ShutdownGrace))
+			if daemon.HungPolicy == HungTerminate {
+				m.RequestShutdown(fmt.Sprintf(`Daemon %s hung`, daemon.Name))
+			}
+		}
+	}
+	xb25.Took = time.Since(xb01)
+	if xb25.Result == ShutdownClean {
+		xb30 := fmt.Sprintf (
+			`PROJECT: Daemon %s: Shutdown successful`, daemon.Name,
+		)
+		m.logg ("OUT", "Main", xb30)
+	}
+	return xb25
+}
+
+/* Starts all registered daemons in dependency order. Initializes communication (flap, clap) for each daemon.
+ * Launches daemon execution and waits for startup success or failure before starting the daemons that depend on it.
+ * Returns an error if the dependency graph is invalid (unknown dependency or cycle)
+*/
+func (m *Manager) DaemonStartUp() error {
+		xb05, xb10 := m.DaemonOrder()
+		if xb10 != nil {
+			return xb10
+		}
+		xb15 := map[string]bool{}
+		for _ , xc10 := range xb05 {
+		/***1***/ // Daemon has no program running
+		if xc10.Program == nil && xc10.ContextProgram == nil {
+			xd05 := fmt.Sprintf (
+				`PROJECT: Daemon %s: Skipping (Daemon has no program to run)`,
+				xc10.Name ,
+			)
+			m.logg ("OUT", "Main", xd05)
+			continue
+		}
+		/***2***/ // Dependencies must be up and running
+		xc12 := ""
+		for _ , xd10 := range xc10.DependsOn {
+			if xb15[xd10] == false {
+				xc12 = xd10
+				break
+			}
+		}
+		if xc12 != "" {
+			xd05 := fmt.Sprintf (
+				`PROJECT: Daemon %s: Skipping (Dependency %s is not running)`,
+				xc10.Name , xc12,
+			)
+			m.logg ("ERR", "Main", xd05)
+			continue
+		}
+		/***3***/ // Starting up daemon
+		if m.DaemonStart(xc10) {
+			xb15[xc10.Name] = true
+		}
+	}
+		/***4***/ // The process can't run without its critical daemons
+		xb20 := []string{}
+		for _ , xc10 := range xb05 {
+			if xb15[xc10.Name] || (xc10.Program == nil && xc10.ContextProgram == nil) {
+				continue
+			}
+			xb20 = append(xb20, xc10.Name)
+			if xc10.Critical {
+				m.RequestShutdown(fmt.Sprintf(`Critical daemon %s not started`, xc10.Name))
+			}
+		}
+		m.mutex.Lock()
+		m.notStarted = xb20
+		m.mutex.Unlock()
+		/***5***/ // systemd readiness and watchdog (Type=notify)
+		m.systemdReady()
+		return nil
+	}
+
+/* Starts a single daemon and supervises it (restarts, health checks) until it is stopped.
+ * Dependencies are not checked here.
+ * Returns true if the daemon reported a successful startup
+*/
+func (m *Manager) DaemonStart(daemon *Daemon) bool {
+	m.lifecycle.Lock()
+	defer m.lifecycle.Unlock()
+	// No daemon starts once the shutdown has begun
+	m.mutex.Lock()
+	xb01 := m.stopping
+	m.mutex.Unlock()
+	if xb01 {
+		return false
+	}
+
+	daemon.mutex.Lock()
+	if daemon.StartupGrace == 0 {
+		daemon.StartupGrace = m.StartupGrace
+	}
+	if daemon.ShutdownGrace == 0 {
+		daemon.ShutdownGrace = m.ShutdownGrace
+	}
+	daemon.halt = make (chan struct{})
+	daemon.exit = make (chan ExecutionOutcome, 1)
+	daemon.replies = make (chan CommandResult, 1)
+	daemon.hung = make (chan *daemonContext, 1)
+	daemon.mutex.Unlock()
+	xb05, _ := m.DaemonLaunch(daemon)
+	if xb05 {
+		go m.DaemonWatch(daemon)
+		if daemon.HealthCheck != nil {
+			go m.DaemonHealth(daemon)
+		}
+		if daemon.HeartbeatTimeout > 0 {
+			go m.DaemonHeartbeat(daemon)
+		}
+	}
+	return xb05
+}
+
+/* Launches a single run of a daemon program and performs the startup handshake.
+ * Creates fresh communication channels (flap, clap) for the run.
+ * Returns true if the daemon reported a successful startup, along with the execution outcome if the program already exited
+*/
+func (m *Manager) DaemonLaunch(daemon *Daemon) (bool, *ExecutionOutcome) {
+	/***1***/
+	daemon.mutex.Lock()
+	daemon.clap = make (chan DaemonCommand, 1)
+	daemon.flap = make (chan DaemonMessage, 1)
+	daemon.context = newDaemonContext ()
+	daemon.manager = m
+	daemon.mutex.Unlock()
+	daemon.setPhase(PhaseStarting, "")
+	m.publish(EventStarted, daemon.Name)
+	xb05 := fmt.Sprintf (
+		`PROJECT: Daemon %s: Starting up... Please wait`, daemon.Name,
+	)
+	m.logg ("OUT", "Main", xb05)
+
+	/***2***/
+	xb10 := make (chan bool, 1)
+	go m.DaemonRun(daemon, xb10)
+
+	/***3***/
+	xb15 := make (chan bool , 1)
+	return m.DaemonShutDownSignal(daemon, xb15)
+}
+
+/* Watches a daemon after a successful startup until it exits for good.
+ * Outcomes of daemons that were asked to shut down are handed over to DaemonShutDown.
+ * Unexpected exits are logged and the daemon's restart policy is applied, escalating to a process shutdown once the restart budget is used up.
+ * A critical daemon that isn't restarted shuts the process down
+*/
+func (m *Manager) DaemonWatch(daemon *Daemon) {
+	var xb05 *ExecutionOutcome
+	// Channels of this start; DaemonStart replaces them once the daemon has been stopped
+	daemon.mutex.Lock()
+	xb01, xb02, xb03, xb04 := daemon.halt, daemon.exit, daemon.replies, daemon.hung
+	daemon.mutex.Unlock()
+	defer func() {
+		daemon.mutex.Lock()
+		daemon.restarting = false
+		daemon.mutex.Unlock()
+	}()
+	for {
+		/***1***/ // Wait for the execution outcome of the current run, passing on command replies and heartbeats
+		daemon.mutex.Lock()
+		xb07, xb08 := daemon.flap, daemon.context
+		daemon.mutex.Unlock()
+		xb09 := false
+		for xb05 == nil {
+			select {
+			case xc01 := <- xb07:
+				switch xc05 := xc01.(type) {
+				case ExecutionOutcome:
+					xb05 = &xc05
+				case CommandResult:
+					select {
+					case xb03 <- xc05:
+					default:
+					}
+				case Heartbeat:
+					daemon.mutex.Lock()
+					daemon.status.HeartbeatAt = time.Now()
+					daemon.mutex.Unlock()
+				}
+			case xc05 := <- xb04:
+				if xc05 == xb08 {
+					xb05 = &ExecutionOutcome{ Code: 504, Note: daemon.Snapshot().LastError }
+					xb09 = true
+				}
+			}
+		}
+		select {
+		case <- xb01:
+			xb02 <- *xb05
+			return
+		default:
+		}
+
+		/***2***/ // Hung: the run was abandoned. HungRestart restarts it whatever the restart policy, within the same budget and backoff
+		if xb09 && daemon.HungPolicy == HungTerminate {
+			m.RequestShutdown(fmt.Sprintf(`Daemon %s hung`, daemon.Name))
+			xb02 <- *xb05
+			return
+		}
+
+		/***3***/ // Unexpected exit
+		xb10 := xb05.Code != 200
+		if xb09 {
+			xc05 := fmt.Sprintf (
+				`PROJECT: Daemon %s: Hung [%s]`, daemon.Name , xb05.Note,
+			)
+			m.logg ("ERR", "Main", xc05)
+		} else if xb10 {
+			xc05 := fmt.Sprintf (
+				`PROJECT: Daemon %s: Encountered error [%s]`, daemon.Name , xb05.Note,
+			)
+			m.logg ("ERR", "Main", xc05)
+		} else {
+			xc05 := fmt.Sprintf (
+				`PROJECT: Daemon %s: Finished`, daemon.Name,
+			)
+			m.logg ("OUT", "Main", xc05)
+		}
+		if xb09 == false && daemon.Restart.Allows(xb10) == false {
+			if daemon.Critical {
+				m.RequestShutdown(fmt.Sprintf(`Critical daemon %s exited`, daemon.Name))
+			}
+			xb02 <- *xb05
+			return
+		}
+
+		/***4***/ // Restart budget
+		xb15 := time.Now()
+		daemon.mutex.Lock()
+		daemon.restarts = slices.DeleteFunc(daemon.restarts, func(t time.Time) bool {
+			return daemon.Restart.Window != 0 && xb15.Sub(t) > daemon.Restart.Window
+		})
+		xb20 := len(daemon.restarts)
+		daemon.mutex.Unlock()
+		if daemon.Restart.MaxRestarts != 0 && xb20 >= daemon.Restart.MaxRestarts {
+			xc05 := fmt.Sprintf (
+				`PROJECT: Daemon %s: Restart budget used up [%d restart(s) within %v]`,
+				daemon.Name, xb20, daemon.Restart.Window,
+			)
+			m.logg ("ERR", "Main", xc05)
+			if daemon.Restart.Escalate || daemon.Critical {
+				m.RequestShutdown(fmt.Sprintf(`Daemon %s could not be kept running`, daemon.Name))
+			}
+			xb02 <- *xb05
+			return
+		}
+
+		/***5***/ // Backoff, unless a shutdown comes first
+		xb25 := daemon.Restart.Delay(xb20)
+		xb30 := fmt.Sprintf (
+			`PROJECT: Daemon %s: Restarting in %v (restart %d)`, daemon.Name, xb25, xb20 + 1,
+		)
+		m.logg ("OUT", "Main", xb30)
+		m.publish(EventRestarting, daemon.Name, "reason", xb05.Note, "delay", xb25, "restarts", xb20 + 1)
+		daemon.mutex.Lock()
+		daemon.restarting = true
+		daemon.mutex.Unlock()
+		select {
+			case <- time.After(xb25):
+			case <- xb01:
+				return
+		}
+
+		/***6***/ // Relaunch through the normal startup handshake, serialized with DaemonStop so a stop can't take the old run's channels
+		m.lifecycle.Lock()
+		select {
+		case <- xb01:
+			m.lifecycle.Unlock()
+			return
+		default:
+		}
+		daemon.mutex.Lock()
+		daemon.restarts = append(daemon.restarts, time.Now())
+		daemon.status.Restarts++
+		daemon.restarting = false
+		daemon.mutex.Unlock()
+		_, xb05 = m.DaemonLaunch(daemon)
+		m.lifecycle.Unlock()
+	}
+}
+
+/* Sends a command to a running daemon and waits for its reply.
+ * Takes the daemon name, the command kind and how long to wait for the reply
+ * Returns the daemon's CommandResult, or an error if the daemon isn't running or didn't reply in time
+*/
+func (m *Manager) DaemonCommand(name string, kind CommandKind, timeout time.Duration) (CommandResult, error) {
+	/***1***/
+	xb10 := m.lookup(name)
+	if xb10 == nil {
+		return CommandResult{}, fmt.Errorf(`daemon %s is not registered`, name)
+	}
+	xb10.mutex.Lock()
+	xb15 := xb10.clap
+	xb20 := xb10.replies
+	xb22 := xb10.halt != nil && xb10.Halted()
+	xb10.mutex.Unlock()
+	if xb15 == nil || xb10.Active() == false || xb22 {
+		return CommandResult{}, fmt.Errorf(`daemon %s is not running`, name)
+	}
+
+	/***2***/
+	select {
+		case xb15 <- DaemonCommand{ Kind: kind }:
+		case <- time.After(timeout):
+			return CommandResult{}, fmt.Errorf(`daemon %s did not accept command %s in time`, name, kind)
+	}
+	for {
+		select {
+			case xc05 := <- xb20:
+				if xc05.Command != kind {
+					continue
+				}
+				return xc05, nil
+			case <- time.After(timeout):
+				return CommandResult{}, fmt.Errorf(`daemon %s did not reply to command %s in time`, name, kind)
+		}
+	}
+}
+
+/* Asks every running daemon (or only the named ones), in startup order, to reload its configuration.
+ * Each daemon's reply is logged, including daemons that report reload as not supported
+ * Returns the replies by daemon name; a daemon that didn't reply is reported with code 503
+*/
+func (m *Manager) DaemonReload(names ...string) map[string]CommandResult {
+	xb05, xb10 := m.DaemonOrder()
+	if xb10 != nil {
+		xb05 = m.daemons()
+	}
+	xb15 := map[string]CommandResult{}
+	for _ , xc10 := range xb05 {
+		if xc10.Active() == false || (len(names) != 0 && slices.Contains(names, xc10.Name) == false) {
+			continue
+		}
+		xc15, xc20 := m.DaemonCommand(xc10.Name, CommandReload, DaemonCommandTimeout)
+		if xc20 != nil {
+			xb15[xc10.Name] = CommandResult{ Command: CommandReload, Code: 503, Note: xc20.Error() }
+		} else {
+			xb15[xc10.Name] = xc15
+		}
+		switch {
+			case xc20 != nil:
+				m.logg("ERR", "Main", fmt.Sprintf(`PROJECT: Daemon %s: Reload failed [%s]`, xc10.Name, xc20.Error()))
+			case xc15.Code == 200:
+				m.logg("OUT", "Main", fmt.Sprintf(`PROJECT: Daemon %s: Reloaded [%s]`, xc10.Name, xc15.Note))
+			case xc15.Code == 501:
+				m.logg("OUT", "Main", fmt.Sprintf(`PROJECT: Daemon %s: Reload not supported [%s]`, xc10.Name, xc15.Note))
+			default:
+				m.logg("ERR", "Main", fmt.Sprintf(`PROJECT: Daemon %s: Reload failed [%s]`, xc10.Name, xc15.Note))
+		}
+	}
+	return xb15
+}
+
+/* Requests an orderly shutdown of the whole process.
+ * Safe to call more than once and from any goroutine; only the first reason is logged
+*/
+func (m *Manager) RequestShutdown(reason string) {
+	xb05 := m.ShutdownRequested()
+	m.shutdownOnce.Do(func() {
+		m.logg("OUT", "Manager", fmt.Sprintf("Shutdown requested [%s]", reason))
+		close(xb05)
+	})
+}
+
+/* Returns a channel that is closed once a shutdown of the whole process has been requested
+*/
+func (m *Manager) ShutdownRequested() chan struct{} {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.shutdown == nil {
+		m.shutdown = make(chan struct{})
+	}
+	return m.shutdown
+}
+
+/* Resolves the order in which daemons are started, dependencies first.
+ * Daemons that don't depend on each other keep their register order.
+ * Returns an error if a daemon is registered twice, depends on an unknown daemon or is part of a dependency cycle
+*/
+func (m *Manager) DaemonOrder() ([]*Daemon, error) {
+	/***1***/
+	xb01 := m.daemons()
+	xb05 := map[string]*Daemon{}
+	for _ , xc10 := range xb01 {
+		if _, xc15 := xb05[xc10.Name]; xc15 {
+			return nil, fmt.Errorf(`daemon %s is registered more than once`, xc10.Name)
+		}
+		xb05[xc10.Name] = xc10
+	}
+	for _ , xc10 := range xb01 {
+		for _ , xd10 := range xc10.DependsOn {
+			if _, xd15 := xb05[xd10]; xd15 == false {
+				return nil, fmt.Errorf(`daemon %s depends on unknown daemon %s`, xc10.Name, xd10)
+			}
+		}
+	}
+	/***2***/ // Depth first walk: 1 - Visiting; 2 - Ordered
+	xb10 := []*Daemon{}
+	xb15 := map[string]int{}
+	var xb20 func(*Daemon, []string) error
+	xb20 = func(daemon *Daemon, path []string) error {
+		switch xb15[daemon.Name] {
+		case 2:
+			return nil
+		case 1:
+			xd05 := append(path[slices.Index(path, daemon.Name):], daemon.Name)
+			return fmt.Errorf(`dependency cycle detected [%s]`, strings.Join(xd05, " -> "))
+		}
+		xb15[daemon.Name] = 1
+		for _ , xc10 := range daemon.DependsOn {
+			if xc15 := xb20(xb05[xc10], append(path, daemon.Name)); xc15 != nil {
+				return xc15
+			}
+		}
+		xb15[daemon.Name] = 2
+		xb10 = append(xb10, daemon)
+		return nil
+	}
+	for _ , xc10 := range xb01 {
+		if xc15 := xb20(xc10, nil); xc15 != nil {
+			return nil, xc15
+		}
+	}
+	return xb10, nil
+}
+
+/* Executes a single daemon program. 
+ * Takes a daemon instance and a status channel as arguments.
+ * Runs the daemon program, captures panics safely, updates state, and sends the execution status to the status channel.
+ * The status channel is used to signal the completion of the daemon execution (true-> done, false -> not done)
+*/
+
+func (m *Manager ) DaemonRun (daemon *Daemon, status chan bool) {
+
+	// Channels of this run; a hung run keeps its own once it is abandoned and the daemon runs again
+	daemon.mutex.Lock ()
+	xb01, xb02, xb03 := daemon.clap, daemon.flap, daemon.context
+	daemon.mutex.Unlock ()
+	defer func (  ) {
+		xc05 := recover ( )
+		if xc05 ==  nil { return }
+
+		xc15 := string (debug.Stack ())
+		m.publish (EventPanicked, daemon.Name, "panic", fmt.Sprint (xc05), "stack", xc15, "abandoned", xb03.isAbandoned ())
+		if xb03.isAbandoned () {
+			m.logg ("ERR", "Main", fmt.Sprintf (`PROJECT: Daemon %s: Abandoned run paniced [%v]`, daemon.Name, xc05))
+			return
+		}
+		xc10 := fmt.Sprintf (
+			`Paniced [%v : %s]`, xc05, xc15,
+		)
+		daemon.setPhase(PhaseFailed, fmt.Sprintf (`Paniced [%v]`, xc05))
+		xb02 <- ExecutionOutcome { Code: 500, Note: xc10 }
+		status <- true
+	} ( )
+
+	defer xb03.cancel ()
+	var xb05 error
+	if daemon.ContextProgram != nil {
+		xb05 = daemon.ContextProgram (xb03, xb01, xb02)
+	} else {
+		xb05 = daemon.Program (xb01, xb02)
+	}
+	xb10 := ExecutionOutcome { Code: 200 }
+	if xb05 != nil {
+		xb10.Code = 500
+		xb10.Note = xb05.Error ()
+	}
+	if xb03.isAbandoned () {
+		m.publish (EventStopped, daemon.Name, "code", xb10.Code, "note", xb10.Note, "abandoned", true)
+		m.logg ("OUT", "Main", fmt.Sprintf (`PROJECT: Daemon %s: Abandoned run returned`, daemon.Name))
+		return
+	}
+	if xb05 != nil {
+		daemon.setPhase(PhaseFailed, xb10.Note)
+	} else if daemon.Snapshot().Phase != PhaseFailed {
+		daemon.setPhase(PhaseStopped, "")
+	}
+	m.publish (EventStopped, daemon.Name, "code", xb10.Code, "note", xb10.Note)
+	xb02 <- xb10
+	status <- true
+} 
+
+/* Helper function for DaemonStartUp. Handles Errors and signals during startup that indicate success or failure.
+ * Returns true once the daemon reports a successful startup, along with the execution outcome if the program exited instead
+*/
+func (m *Manager) DaemonShutDownSignal(daemon *Daemon, status chan bool) (bool, *ExecutionOutcome) {
+	if daemon.StartupGrace != 0  {
+			go func (  ) {
+				time.Sleep (daemon.StartupGrace)
+				status <- true
+			}  (  )
+		}
+		for {
+		select  {
+			case xe05 := <- daemon.flap: {
+				switch xf05 := xe05.(type) {
+				case ExecutionOutcome:
+					xg05 := fmt.Sprintf (
+						`PROJECT: Daemon %s: Startup failed [%s]`,
+						daemon.Name , "Program exited before reporting startup",
+					)
+					m.logg ("ERR", "Main", xg05)
+					m.publish(EventStartupFailed, daemon.Name, "note", "Program exited before reporting startup")
+					return false, &xf05
+				case StartupResult:
+					if xf05.Code != 200 {
+						xg05 := fmt.Sprintf (
+							`PROJECT: Daemon %s: Startup failed [%s]`,
+							daemon.Name , xf05.Note,
+						)
+						m.logg ("ERR", "Main", xg05)
+						daemon.setPhase(PhaseFailed, xf05.Note)
+						m.publish(EventStartupFailed, daemon.Name, "note", xf05.Note)
+						return false, nil
+					}
+					daemon.mutex.Lock()
+					if daemon.status.Phase == PhaseStarting {
+						daemon.status.Phase = PhaseRunning
+					}
+					daemon.mutex.Unlock()
+					xg10 := fmt.Sprintf (`PROJECT: Daemon %s: Up and running`, daemon.Name)
+					m.logg ("OUT", "Main", xg10)
+					m.publish(EventRunning, daemon.Name)
+					return true, nil
+				}
+			}
+			case _= <- status:{
+				xe10 := fmt.Sprintf (
+					`PROJECT: Daemon %s: Startup failed [%s]`,
+					daemon.Name , "Startup grace period expired",
+				)
+				m.logg ("ERR", "Main", xe10)
+				daemon.setPhase(PhaseFailed, "Startup grace period expired")
+				m.publish(EventStartupFailed, daemon.Name, "note", "Startup grace period expired")
+				return false, nil
+			}
+		}
+		}
+	}
+
+/* Listens for OS shutdown, reload and diagnostic signals.
+ * Takes a signal channel as argument.
+ * Shutdown signals request the shutdown of the whole process; reload signals are passed on to the daemons; diagnostic signals run their handler.
+ * Daemon exits are handled by DaemonWatch (restarts, critical daemons). Returns once a shutdown is requested
+*/
+func (m *Manager) Supervise(SigChannel chan os.Signal) { 
+	for _ , xc10 := range m.ShutdownSignal { signal.Notify (SigChannel , xc10) }
+	for _ , xc10 := range m.ReloadSignal { signal.Notify (SigChannel , xc10) }
+	for xc10 := range m.DiagnosticSignal { signal.Notify (SigChannel , xc10) }
+	for     {
+		select  {
+			case xd05 := <- SigChannel:{
+				if xd10, xd15 := xd05.(syscall.Signal); xd15 && m.DiagnosticSignal[xd10] != nil {
+					m.logg("OUT", "Manager", fmt.Sprintf("Diagnostic signal received [%s]", xd05))
+					go m.DiagnosticSignal[xd10]()
+					continue
+				}
+				if xd10, xd15 := xd05.(syscall.Signal); xd15 && slices.Contains(m.ReloadSignal, xd10) {
+					m.logg("OUT", "Manager", fmt.Sprintf("Reload signal received [%s]", xd05))
+					go m.DaemonReload()
+					continue
+				}
+				m.RequestShutdown(fmt.Sprintf("Shutdown signal received [%s]", xd05))
+				return
+			}
+			// Signals that follow are left in SigChannel, so they don't end the process during the shutdown
+			case _ = <- m.ShutdownRequested():{
+				return
+			}
+		}
+	}
+}
+//============================================================================================//
+//12345678901234567890123456789012345678901234567890123456789012345678901234567890123456789012//
+//12345678901234567890123456789012345678901234567890123456789012345678901234567890123456789012//
+//============================================================================================//
+type    Daemon struct  {
+	Name   string
+	Program  DaemonProgram // map based programs are wrapped with MapProgram
+	ContextProgram  DaemonContextProgram // used instead of Program when set (DHI runs as one)
+	StartupGrace     time.Duration
+	ShutdownGrace    time.Duration
+	DependsOn        []string  // names of daemons that must be up and running before this one starts
+	Restart          RestartPolicy
+	HealthCheck      func (context.Context) (error)  // optional; polled every HealthInterval while the daemon runs
+	HealthInterval   time.Duration  // 0 - DefaultHealthInterval
+	Critical         bool  // the process shuts down if the daemon doesn't start, or exits and its restart policy doesn't bring it back
+	HeartbeatTimeout time.Duration  // 0 - no heartbeats expected; see Heartbeat and DaemonHeartbeat
+	HungPolicy       HungPolicy  // "" - HungRestart
+	// internal use: don't set properties below
+	clap   chan DaemonCommand
+	flap   chan DaemonMessage
+	exit   chan ExecutionOutcome  // execution outcome handed over to DaemonShutDown
+	replies  chan CommandResult  // replies to commands sent with DaemonCommand
+	halt   chan struct{}  // closed once the daemon is asked to shut down
+	hung   chan *daemonContext  // abandoned runs handed to DaemonWatch, see daemonHung
+	context  *daemonContext  // context of the current run
+	manager  *Manager  // manager that launched the current run
+	restarts  []time.Time
+	restarting bool  // DaemonWatch is waiting to restart the failed run
+	status    DaemonSnapshot  // read through Snapshot
+	mutex  sync.Mutex  // protects the properties above
+}
+
+/* Reports whether the daemon has been asked to shut down. The caller holds the daemon's mutex
+*/
+func (daemon *Daemon) Halted() bool {
+	select {
+		case <- daemon.halt:
+			return true
+		default:
+			return false
+	}
+}
+
+/* Describes when and how often a daemon is restarted after its program exits.
+ * The zero value never restarts, which matches the behaviour of daemons that don't set a policy
+*/
+type RestartPolicy struct {
+	When         RestartWhen
+	Backoff      time.Duration  // delay before the first restart, doubled for every restart within Window
+	MaxBackoff   time.Duration  // upper bound for the delay (0 - no bound)
+	MaxRestarts  int            // restarts allowed within Window (0 - unlimited)
+	Window       time.Duration  // period over which restarts are counted (0 - process lifetime)
+	Escalate     bool           // shut the whole process down once MaxRestarts is used up
+}
+type RestartWhen string
+const (
+	RestartNever     RestartWhen = "never"
+	RestartOnFailure RestartWhen = "on-failure"
+	RestartAlways    RestartWhen = "always"
+)
+
+/* Reports whether the policy restarts a daemon that exited, given whether the exit was a failure
+ * Anything other than on-failure and always never restarts
+*/
+func (p RestartPolicy) Allows(failed bool) bool {
+	return p.When == RestartAlways || (p.When == RestartOnFailure && failed)
+}
+
+/* Returns the delay before the next restart, given the number of restarts already counted within the window
+*/
+func (p RestartPolicy) Delay(restarts int) time.Duration {
+	xb05 := p.Backoff
+	for xc05 := 0; xc05 < restarts && xb05 > 0; xc05++ {
+		if p.MaxBackoff != 0 && xb05 >= p.MaxBackoff {
+			break
+		}
+		xb05 = xb05 * 2
+	}
+	if p.MaxBackoff != 0 && xb05 > p.MaxBackoff {
+		xb05 = p.MaxBackoff
+	}
+	return xb05
+}
+/* Writes a line of the manager's own through its Logger
+*/
+func (m *Manager) logg (Type, Source, Output string) {
+	m.logger ().Output (Type, Source, Output)
+}
+func (m *Manager) logger () *Logger {
+	if m.Logger == nil {
+		return Logg
+	}
+	return m.Logger
+}
+
+/* Compatibility shim over Logg: Type "OUT" logs at info, "ERR" at error ("DBG" and "WRN" are also understood)
+*/
+func    Output_Logg (Type, Source, Output string) {
+	Logg.Output (Type, Source, Output)
+}
+
+/* Coordinates the lifecycle of all daemons. References shared  communication channels and supported OS shutdown signals
+ * Controls startup, supervision, error handling, and graceful shutdown. Created with New, or as a literal for in-process use
+*/
+type Manager struct {
+	Daemons 		[]*Daemon
+	SignalCh 		chan os.Signal
+	ShutdownSignal	[]syscall.Signal
+	ReloadSignal	[]syscall.Signal
+	DiagnosticSignal	map[syscall.Signal]func ()  // handlers of signals that leave the process running
+	ControlSocket	string  // path of the admin control socket ("" - none), see ServeControl
+	DumpDir		string  // directory goroutine dumps are written to ("" - working directory), see DumpGoroutines
+	Handoff		func () (map[string]net.Listener, error)  // prepares an Upgrade: saves state, returns the listeners to pass on (nil - upgrade not supported)
+	Logger		*Logger  // nil - Logg
+	StartupGrace	time.Duration  // used for daemons that set no StartupGrace
+	ShutdownGrace	time.Duration  // used for daemons that set no ShutdownGrace
+	ExitCodes	ExitCodes  // returned by Run
+	OnShutdown	[]func ()  // run by Run once the shutdown begins, before the daemons are stopped
+	JournalPath	string  // JSON-lines file Run appends the lifecycle events to ("" - none), see Journal
+	JournalMaxSize	int64  // bytes after which the journal is rotated (0 - never)
+	JournalMaxBackups	int  // rotated journals kept (0 - all)
+	// internal use: don't set properties below
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+	mutex        sync.Mutex
+	lifecycle    sync.Mutex  // serializes DaemonStart and DaemonStop
+	stopping     bool  // DaemonShutDown has begun
+	notStarted   []string  // daemons whose startup failed or was skipped in the last DaemonStartUp
+	control      net.Listener  // control socket being served
+	upgrading    sync.Mutex
+	upgraded     bool  // Upgrade handed over to a new process
+	register     sync.Mutex  // serializes AddDaemon and RemoveDaemon
+	events       sync.Mutex  // protects subscribers and journals, serializes the journal writes
+	subscribers  map[chan Event]struct{}  // see Subscribe
+	journals     map[*journal]struct{}  // see Journal
+}
@@ -0,0 +1,123 @@
+package daemoncore
+
+import  "encoding/json"
+import  "fmt"
+import  "io"
+import  "time"
+
+/* Lifecycle events published by the manager, see Subscribe
+*/
+type    EventKind string
+const (
+	EventStarted       EventKind = "started"         // a run of the daemon was launched
+	EventStartupFailed EventKind = "startup-failed"  // details: note
+	EventRunning       EventKind = "running"         // the program reported a successful startup
+	EventHealthChanged EventKind = "health-changed"  // details: phase (running or degraded), note
+	EventRestarting    EventKind = "restarting"      // details: reason, delay, restarts
+	EventStopping      EventKind = "stopping"        // the daemon was asked to shut down
+	EventStopped       EventKind = "stopped"         // details: code, note, abandoned (a hung run that returned after all)
+	EventPanicked      EventKind = "panicked"        // details: panic, stack
+)
+
+type    Event struct {
+	Time     time.Time       `json:"time"`
+	Kind     EventKind       `json:"kind"`
+	Daemon   string          `json:"daemon"`
+	Details  map[string]any  `json:"details,omitempty"`
+}
+
+/* Returns a channel receiving every lifecycle event from now on, and the function ending the subscription (which closes the channel).
+ * Events are never waited for: one that doesn't fit into the channel's buffer is dropped for this subscriber
+*/
+func (m *Manager) Subscribe (buffer int) (<-chan Event, func ()) {
+	xb05 := make (chan Event, buffer)
+	m.events.Lock ()
+	if m.subscribers == nil {
+		m.subscribers = map[chan Event]struct{} {}
+	}
+	m.subscribers [xb05] = struct{} {}
+	m.events.Unlock ()
+	return xb05, func () {
+		m.events.Lock ()
+		defer m.events.Unlock ()
+		if _ , xc05 := m.subscribers [xb05]; xc05 {
+			delete (m.subscribers, xb05)
+			close (xb05)
+		}
+	}
+}
+
+/* Hands an event to every subscriber. Details are alternating keys and values, as for Logger fields
+*/
+func (m *Manager) publish (kind EventKind, daemon string, details ...any) {
+	xb05 := Event { Time: time.Now (), Kind: kind, Daemon: daemon }
+	if len (details) > 0 {
+		xb05.Details = map[string]any {}
+		for xc05 := 0; xc05 + 1 < len (details); xc05 += 2 {
+			xb05.Details [fmt.Sprint (details [xc05])] = details [xc05 + 1]
+		}
+	}
+	m.events.Lock ()
+	defer m.events.Unlock ()
+	for xc05 := range m.subscribers {
+		select {
+		case xc05 <- xb05:
+		default:
+		}
+	}
+	for xc05 := range m.journals {
+		m.journalEvent (xc05, xb05)
+	}
+}
+
+/* Appends every event to w as one JSON object per line, from now until the returned function is called.
+ * Entries are written by publish itself, so none is dropped and they keep the order of the events; the stopped and panicked ones are
+ * synced to storage if w has a Sync method (as *os.File and *RotatingFile do), so they survive a crash of the process right after
+*/
+func (m *Manager) Journal (w io.Writer) func () {
+	xb05 := &journal { writer: w, encoder: json.NewEncoder (w) }
+	m.events.Lock ()
+	if m.journals == nil {
+		m.journals = map[*journal]struct{} {}
+	}
+	m.journals [xb05] = struct{} {}
+	m.events.Unlock ()
+	return func () {
+		m.events.Lock ()
+		defer m.events.Unlock ()
+		delete (m.journals, xb05)
+	}
+}
+
+type    journal struct {
+	writer   io.Writer
+	encoder  *json.Encoder
+}
+
+/* Writes the event to the journal; called with m.events held
+*/
+func (m *Manager) journalEvent (j *journal, event Event) {
+	// Durations are written as "1.5s" rather than nanoseconds; the details are shared with the subscribers
+	if len (event.Details) > 0 {
+		xc05 := make (map[string]any, len (event.Details))
+		for xd05, xd10 := range event.Details {
+			if xd15, xd20 := xd10.(time.Duration); xd20 {
+				xd10 = xd15.String ()
+			}
+			xc05 [xd05] = xd10
+		}
+		event.Details = xc05
+	}
+	if xb05 := j.encoder.Encode (event); xb05 != nil {
+		m.logg ("ERR", "Manager", fmt.Sprintf ("Journal entry not written [%s]", xb05.Error ()))
+		return
+	}
+	if event.Kind != EventStopped && event.Kind != EventPanicked {
+		return
+	}
+	if xb10, xb15 := j.writer.(interface { Sync () error }); xb15 {
+		if xb20 := xb10.Sync (); xb20 != nil {
+			m.logg ("ERR", "Manager", fmt.Sprintf ("Journal not synced [%s]", xb20.Error ()))
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package daemoncore
+
+import  "context"
+import  "fmt"
+import  "math/rand/v2"
+import  "strconv"
+import  "strings"
+import  "sync"
+import  "time"
+
+/* Periodic job run by a daemon, see NewJobDaemon.
+ * Runs every Every, or at the times matched by Cron, each run delayed by a random part of Jitter
+*/
+type    Job struct {
+	Every    time.Duration
+	Cron     string  // "minute hour day-of-month month day-of-week" in local time, or @hourly, @daily, @weekly, @monthly; used instead of Every when set
+	Jitter   time.Duration
+	Overlap  JobOverlap
+	Run      func (ctx context.Context) (error)  // ctx is cancelled when the daemon is stopped
+}
+
+/* What happens when a run is due while the previous one is still running
+*/
+type    JobOverlap string
+const (
+	OverlapSkip  JobOverlap = "skip"   // the run is skipped (default)
+	OverlapQueue JobOverlap = "queue"  // the run starts once the previous one finishes; runs due meanwhile are skipped
+	OverlapAllow JobOverlap = "allow"  // the runs overlap
+)
+
+/* Creates a daemon running the job until the daemon is stopped; the runs still in progress are waited for.
+ * A failed run is logged and recorded as the daemon's last error (NOTE in lytupctl list); the status command reports the run counts.
+ * The loop sends a Heartbeat every HeartbeatInterval, so a HeartbeatTimeout may be set on the daemon.
+ * Returns an error if the schedule or overlap policy is invalid
+*/
+func    NewJobDaemon (name string, job Job) (*Daemon, error) {
+	/***1***/
+	if job.Run == nil {
+		return nil, fmt.Errorf (`job %s has nothing to run`, name)
+	}
+	var xb05 func (time.Time) time.Time
+	switch {
+	case job.Cron != "":
+		xc05, xc10 := parseCron (job.Cron)
+		if xc10 != nil {
+			return nil, fmt.Errorf (`job %s: %s`, name, xc10.Error ())
+		}
+		if xc05.next (time.Now ()).IsZero () {
+			return nil, fmt.Errorf (`job %s: cron expression %q never matches`, name, job.Cron)
+		}
+		xb05 = xc05.next
+	case job.Every > 0:
+		xb05 = func (t time.Time) time.Time { return t.Add (job.Every) }
+	default:
+		return nil, fmt.Errorf (`job %s needs an interval or a cron expression`, name)
+	}
+	switch job.Overlap {
+	case "":
+		job.Overlap = OverlapSkip
+	case OverlapSkip, OverlapQueue, OverlapAllow:
+	default:
+		return nil, fmt.Errorf (`job %s: unknown overlap policy %q`, name, job.Overlap)
+	}
+	if job.Jitter < 0 {
+		return nil, fmt.Errorf (`job %s: jitter must not be negative`, name)
+	}
+
+	/***2***/
+	xb10 := &Daemon { Name: name, StartupGrace: time.Second * 5, ShutdownGrace: time.Second * 30 }
+	xb10.ContextProgram = func (ctx context.Context, Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) (error) {
+		xb10.mutex.Lock ()
+		xc05 := xb10.manager
+		xb10.mutex.Unlock ()
+		return job.loop (ctx, xc05, xb10, xb05, Clap, Flap)
+	}
+	return xb10, nil
+}
+
+func (job Job) loop (ctx context.Context, m *Manager, daemon *Daemon, next func (time.Time) time.Time, Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) (error) {
+	/***1***/
+	Flap <- StartupResult { Code: 200 }
+	var xb05 sync.WaitGroup
+	defer xb05.Wait ()
+	xb10 := make (chan struct{}, 1)  // a run finished (signals may be merged)
+	xb15 := 0  // runs in progress
+	xb20 := false  // a queued run is waiting
+	xb25, xb30 := 0, 0  // runs, failures
+	xb35 := time.Time {}  // last run started
+	xb40 := ""  // last failure
+	xb45 := sync.Mutex {}
+	xb50 := func () {
+		xb15++
+		xb25++
+		xb35 = time.Now ()
+		xb05.Add (1)
+		go func () {
+			defer xb05.Done ()
+			xc05 := job.run (ctx)
+			if xc05 != nil {
+				m.logg ("ERR", "Manager", fmt.Sprintf ("Job %s: Run failed [%s]", daemon.Name, xc05.Error ()))
+				daemon.mutex.Lock ()
+				daemon.status.LastError = xc05.Error ()
+				daemon.mutex.Unlock ()
+			}
+			xb45.Lock ()
+			xb15--
+			if xc05 != nil {
+				xb30++
+				xb40 = xc05.Error ()
+			}
+			xb45.Unlock ()
+			select {
+			case xb10 <- struct{} {}:
+			default:
+			}
+		} ()
+	}
+
+	/***2***/
+	xb55 := next (time.Now ())
+	xb60 := time.NewTimer (job.delay (xb55))
+	defer xb60.Stop ()
+	xb65 := time.NewTicker (HeartbeatInterval)
+	defer xb65.Stop ()
+	for {
+		select {
+		case <- ctx.Done ():
+			return nil
+		case <- xb65.C:
+			select {
+			case Flap <- Heartbeat {}:
+			default:
+			}
+		case xc05 := <- Clap:
+			switch xc05.Kind {
+			case CommandShutdown:
+				return nil
+			case CommandStatus:
+				xb45.Lock ()
+				xc10 := fmt.Sprintf (`%d run(s), %d failed, %d running, next at %s`, xb25, xb30, xb15, xb55.Format (time.DateTime))
+				if xb35.IsZero () == false {
+					xc10 += fmt.Sprintf (`, last started at %s`, xb35.Format (time.DateTime))
+				}
+				if xb40 != "" {
+					xc10 += fmt.Sprintf (`, last failure [%s]`, xb40)
+				}
+				xb45.Unlock ()
+				Flap <- CommandResult { Command: xc05.Kind, Code: 200, Note: xc10 }
+			default:
+				Flap <- CommandResult { Command: xc05.Kind, Code: 501, Note: fmt.Sprintf (`Command %s not supported`, xc05.Kind) }
+			}
+		case <- xb10:
+			xb45.Lock ()
+			if xb20 && xb15 == 0 {
+				xb20 = false
+				xb50 ()
+			}
+			xb45.Unlock ()
+		case <- xb60.C:
+			xb45.Lock ()
+			switch {
+			case xb15 == 0 || job.Overlap == OverlapAllow:
+				xb50 ()
+			case job.Overlap == OverlapQueue:
+				xb20 = true
+			default:
+				m.logg ("DBG", "Manager", fmt.Sprintf ("Job %s: Skipped, previous run still in progress", daemon.Name))
+			}
+			xb45.Unlock ()
+			// Runs missed while the process was suspended are not made up
+			if xb55 = next (xb55); xb55.Before (time.Now ()) {
+				xb55 = next (time.Now ())
+			}
+			xb60.Reset (job.delay (xb55))
+		}
+	}
+}
+
+/* Runs the job once, turning a panic into an error
+*/
+func (job Job) run (ctx context.Context) (err error) {
+	defer func () {
+		if xc05 := recover (); xc05 != nil {
+			err = fmt.Errorf (`paniced [%v]`, xc05)
+		}
+	} ()
+	return job.Run (ctx)
+}
+
+/* Returns the wait until a run due at the given time, plus jitter
+*/
+func (job Job) delay (due time.Time) time.Duration {
+	xb05 := time.Until (due)
+	if job.Jitter > 0 {
+		xb05 += rand.N (job.Jitter)
+	}
+	return max (xb05, 0)
+}
+
+/* Cron schedule: one bit per allowed value of each field
+*/
+type    cronSchedule struct {
+	minute, hour, dom, month, dow  uint64
+	anyDom, anyDow  bool
+}
+
+/* Parses a 5-field cron expression. Fields take *, numbers, ranges (1-5), lists (1,15) and steps after * or a range (0-30/10);
+ * day-of-week is 0-7 with 0 and 7 both Sunday
+*/
+func    parseCron (expr string) (*cronSchedule, error) {
+	/***1***/
+	xb05 := map[string]string {
+		"@hourly": "0 * * * *", "@daily": "0 0 * * *", "@midnight": "0 0 * * *", "@weekly": "0 0 * * 0", "@monthly": "0 0 1 * *",
+	}
+	if xc05, xc10 := xb05 [expr]; xc10 {
+		expr = xc05
+	}
+	xb10 := strings.Fields (expr)
+	if len (xb10) != 5 {
+		return nil, fmt.Errorf (`cron expression %q needs 5 fields (minute hour day-of-month month day-of-week)`, expr)
+	}
+
+	/***2***/
+	xb15 := &cronSchedule {}
+	xb20 := []struct { bits *uint64; low, high int; name string } {
+		{ &xb15.minute, 0, 59, "minute" }, { &xb15.hour, 0, 23, "hour" }, { &xb15.dom, 1, 31, "day-of-month" },
+		{ &xb15.month, 1, 12, "month" }, { &xb15.dow, 0, 7, "day-of-week" },
+	}
+	for xc05, xc10 := range xb20 {
+		xc15, xc20 := parseCronField (xb10 [xc05], xc10.low, xc10.high)
+		if xc20 != nil {
+			return nil, fmt.Errorf (`cron expression %q: %s %s`, expr, xc10.name, xc20.Error ())
+		}
+		*xc10.bits = xc15
+	}
+	if xb15.dow & (1 << 7) != 0 {
+		xb15.dow |= 1
+	}
+	xb15.anyDom = strings.HasPrefix (xb10 [2], "*")
+	xb15.anyDow = strings.HasPrefix (xb10 [4], "*")
+	return xb15, nil
+}
+func    parseCronField (field string, low, high int) (uint64, error) {
+	var xb05 uint64
+	for _ , xc05 := range strings.Split (field, ",") {
+		xc10, xc15, xc20 := strings.Cut (xc05, "/")
+		xc25 := 1
+		if xc20 {
+			xd05, xd10 := strconv.Atoi (xc15)
+			if xd10 != nil || xd05 <= 0 {
+				return 0, fmt.Errorf (`has an invalid step %q`, xc15)
+			}
+			xc25 = xd05
+		}
+		xc30, xc35 := low, high
+		if xc10 != "*" {
+			xd05, xd10, xd15 := strings.Cut (xc10, "-")
+			var xd20, xd25 error
+			xc30, xd20 = strconv.Atoi (xd05)
+			xc35 = xc30
+			if xd15 {
+				xc35, xd25 = strconv.Atoi (xd10)
+			} else if xc20 {
+				xc35 = high
+			}
+			if xd20 != nil || xd25 != nil || xc30 < low || xc35 > high || xc30 > xc35 {
+				return 0, fmt.Errorf (`value %q is outside %d-%d`, xc10, low, high)
+			}
+		}
+		for xd05 := xc30; xd05 <= xc35; xd05 += xc25 {
+			xb05 |= 1 << xd05
+		}
+	}
+	return xb05, nil
+}
+
+/* Returns the first matching minute after t. Day-of-month and day-of-week match either, unless one of them is *
+ * Returns the zero time if the expression never matches within five years (e.g. 30 February)
+*/
+func (s *cronSchedule) next (t time.Time) time.Time {
+	xb05 := t.Truncate (time.Minute).Add (time.Minute)
+	xb10 := xb05.AddDate (5, 0, 0)
+	for xb05.Before (xb10) {
+		xc05, xc10, xc15 := xb05.Date ()
+		switch {
+		case s.month & (1 << xc10) == 0:
+			xb05 = time.Date (xc05, xc10 + 1, 1, 0, 0, 0, 0, xb05.Location ())
+		case s.dayMatches (xb05) == false:
+			xb05 = time.Date (xc05, xc10, xc15 + 1, 0, 0, 0, 0, xb05.Location ())
+		case s.hour & (1 << xb05.Hour ()) == 0:
+			xb05 = time.Date (xc05, xc10, xc15, xb05.Hour () + 1, 0, 0, 0, xb05.Location ())
+		case s.minute & (1 << xb05.Minute ()) == 0:
+			xb05 = xb05.Add (time.Minute)
+		default:
+			return xb05
+		}
+	}
+	return time.Time {}
+}
+func (s *cronSchedule) dayMatches (t time.Time) bool {
+	xb05 := s.dom & (1 << t.Day ()) != 0
+	xb10 := s.dow & (1 << t.Weekday ()) != 0
+	if s.anyDom || s.anyDow {
+		return xb05 && xb10
+	}
+	return xb05 || xb10
+}
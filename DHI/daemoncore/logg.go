@@ -0,0 +1,359 @@
+package daemoncore
+
+import  "bytes"
+import  "encoding/json"
+import  "errors"
+import  "fmt"
+import  "io"
+import  "os"
+import  "path/filepath"
+import  "slices"
+import  "strconv"
+import  "strings"
+import  "sync"
+import  "time"
+
+/* Leveled, structured logger behind Output_Logg.
+ * Debug and info lines go to stdout, warn and error lines to stderr, and every line to the optional file sink.
+ * Lines are plain text ("[time//Source] LEVEL message key=value") or JSON lines ({"time", "level", "source", "msg", fields...})
+*/
+type    LoggLevel int
+const (
+	LevelDebug LoggLevel = iota - 1
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+func (l LoggLevel) String () string {
+	switch l {
+	case LevelDebug: return "debug"
+	case LevelInfo:  return "info"
+	case LevelWarn:  return "warn"
+	}
+	return "error"
+}
+func    ParseLoggLevel (name string) (LoggLevel, error) {
+	switch strings.ToLower (name) {
+	case "debug": return LevelDebug, nil
+	case "info":  return LevelInfo, nil
+	case "warn":  return LevelWarn, nil
+	case "error": return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf (`unknown log level %q (debug, info, warn, error)`, name)
+}
+
+// Logger settings, as found in the "logging" section of the runtime configuration
+type    LoggConfig struct {
+	Level     string             `json:"level"`
+	Sources   map[string]string  `json:"sources"`  // minimum level per source ("" - use Level)
+	Format    string             `json:"format"`   // text or json
+	TimeZone  string             `json:"time_zone"`  // IANA name, e.g. "Africa/Lagos"
+	File      struct {
+		Path        string  `json:"path"`  // "" - no file sink
+		MaxSizeMB   int     `json:"max_size_mb"`  // rotate once the file reaches this size (0 - never)
+		MaxBackups  int     `json:"max_backups"`  // rotated files kept (0 - all)
+		MaxAgeDays  int     `json:"max_age_days"`  // rotated files older than this are removed (0 - never)
+	} `json:"file"`
+}
+
+type    Logger struct {
+	mutex     sync.Mutex
+	level     LoggLevel
+	sources   map[string]LoggLevel
+	json      bool
+	location  *time.Location
+	stdout    io.Writer
+	stderr    io.Writer
+	file      io.WriteCloser
+	saved     *Logger  // levels to go back to while debug is toggled on, see ToggleDebug
+}
+
+// Process wide logger; configured from the runtime configuration in main
+var     Logg *Logger = &Logger { level: LevelInfo, location: time.UTC, stdout: os.Stdout, stderr: os.Stderr }
+
+/* Applies a configuration. Nothing changes if the configuration is invalid.
+ * Returns an error naming the first invalid setting
+*/
+func (l *Logger) Configure (conf LoggConfig) error {
+	/***1***/
+	xb05, xb10 := ParseLoggLevel (conf.Level)
+	if xb10 != nil {
+		return fmt.Errorf (`logging.level: %s`, xb10.Error ())
+	}
+	xb15 := map[string]LoggLevel {}
+	for xc05, xc10 := range conf.Sources {
+		if xc10 == "" {
+			continue
+		}
+		xc15, xc20 := ParseLoggLevel (xc10)
+		if xc20 != nil {
+			return fmt.Errorf (`logging.sources.%s: %s`, xc05, xc20.Error ())
+		}
+		xb15 [xc05] = xc15
+	}
+	if conf.Format != "text" && conf.Format != "json" {
+		return fmt.Errorf (`logging.format: must be text or json`)
+	}
+	xb20, xb25 := time.LoadLocation (conf.TimeZone)
+	if xb25 != nil {
+		return fmt.Errorf (`logging.time_zone: %s`, xb25.Error ())
+	}
+	var xb30 io.WriteCloser
+	if conf.File.Path != "" {
+		xc05, xc10 := OpenRotatingFile (
+			conf.File.Path, int64 (conf.File.MaxSizeMB) * 1024 * 1024,
+			conf.File.MaxBackups, time.Duration (conf.File.MaxAgeDays) * 24 * time.Hour,
+		)
+		if xc10 != nil {
+			return fmt.Errorf (`logging.file.path: %s`, xc10.Error ())
+		}
+		xb30 = xc05
+	}
+
+	/***2***/
+	l.mutex.Lock ()
+	defer l.mutex.Unlock ()
+	if l.file != nil {
+		l.file.Close ()
+	}
+	l.level, l.sources, l.json, l.location, l.file = xb05, xb15, conf.Format == "json", xb20, xb30
+	l.saved = nil
+	return nil
+}
+
+/* Changes the default minimum level; per-source levels are left as they are
+*/
+func (l *Logger) SetLevel (level LoggLevel) {
+	l.mutex.Lock ()
+	defer l.mutex.Unlock ()
+	l.level = level
+}
+
+/* Switches every source to debug, or back to the levels it had before; a new configuration ends the toggle.
+ * Returns true if debug logging is now on
+*/
+func (l *Logger) ToggleDebug () bool {
+	l.mutex.Lock ()
+	defer l.mutex.Unlock ()
+	if l.saved != nil {
+		l.level, l.sources, l.saved = l.saved.level, l.saved.sources, nil
+		return false
+	}
+	l.saved = &Logger { level: l.level, sources: l.sources }
+	l.level, l.sources = LevelDebug, nil
+	return true
+}
+
+func (l *Logger) Level () LoggLevel {
+	l.mutex.Lock ()
+	defer l.mutex.Unlock ()
+	return l.level
+}
+
+/* Reports whether a line of the given level from source would be written
+*/
+func (l *Logger) Enabled (level LoggLevel, source string) bool {
+	l.mutex.Lock ()
+	defer l.mutex.Unlock ()
+	return l.enabled (level, source)
+}
+func (l *Logger) enabled (level LoggLevel, source string) bool {
+	if xb05, xb10 := l.sources [source]; xb10 {
+		return level >= xb05
+	}
+	return level >= l.level
+}
+
+/* Writes one line. Fields are alternating keys and values
+*/
+func (l *Logger) Log (level LoggLevel, source, message string, fields ...any) {
+	l.mutex.Lock ()
+	defer l.mutex.Unlock ()
+	if l.enabled (level, source) == false {
+		return
+	}
+
+	/***1***/
+	xb05 := time.Now ().In (l.location)
+	xb10 := &bytes.Buffer {}
+	if l.json {
+		xb10.WriteString (`{"time":`)
+		xb10.Write (loggJSON (xb05.Format (time.RFC3339Nano)))
+		xb10.WriteString (`,"level":`)
+		xb10.Write (loggJSON (level.String ()))
+		xb10.WriteString (`,"source":`)
+		xb10.Write (loggJSON (source))
+		xb10.WriteString (`,"msg":`)
+		xb10.Write (loggJSON (message))
+		for xc05 := 0; xc05 < len (fields); xc05 += 2 {
+			xb10.WriteString (`,`)
+			xb10.Write (loggJSON (fmt.Sprint (fields [xc05])))
+			xb10.WriteString (`:`)
+			xb10.Write (loggJSON (loggField (fields, xc05 + 1)))
+		}
+		xb10.WriteString ("}\n")
+	} else {
+		fmt.Fprintf (
+			xb10, `[%s//%s] %s %s`, xb05.Format ("2006-01-02 15:04:05.000 -07:00"), source, strings.ToUpper (level.String ()), message,
+		)
+		for xc05 := 0; xc05 < len (fields); xc05 += 2 {
+			xc10 := fmt.Sprint (loggField (fields, xc05 + 1))
+			if strings.ContainsAny (xc10, " \t\n\"=") || xc10 == "" {
+				xc10 = strconv.Quote (xc10)
+			}
+			fmt.Fprintf (xb10, ` %v=%s`, fields [xc05], xc10)
+		}
+		xb10.WriteString ("\n")
+	}
+
+	/***2***/
+	if level >= LevelWarn {
+		l.stderr.Write (xb10.Bytes ())
+	} else {
+		l.stdout.Write (xb10.Bytes ())
+	}
+	if l.file != nil {
+		l.file.Write (xb10.Bytes ())
+	}
+}
+
+/* Writes one line at the level named by Type, as Output_Logg does
+*/
+func (l *Logger) Output (Type, Source, Output string) {
+	switch strings.ToLower (Type) {
+		case "out", "info": l.Info (Source, Output)
+		case "dbg", "debug": l.Debug (Source, Output)
+		case "wrn", "warn": l.Warn (Source, Output)
+		default: l.Error (Source, Output)
+	}
+}
+
+func (l *Logger) Debug (source, message string, fields ...any) { l.Log (LevelDebug, source, message, fields...) }
+func (l *Logger) Info  (source, message string, fields ...any) { l.Log (LevelInfo, source, message, fields...) }
+func (l *Logger) Warn  (source, message string, fields ...any) { l.Log (LevelWarn, source, message, fields...) }
+func (l *Logger) Error (source, message string, fields ...any) { l.Log (LevelError, source, message, fields...) }
+
+func    loggField (fields []any, index int) any {
+	if index >= len (fields) {
+		return "(missing)"
+	}
+	if xb05, xb10 := fields [index].(error); xb10 {
+		return xb05.Error ()
+	}
+	return fields [index]
+}
+func    loggJSON (value any) []byte {
+	xb05, xb10 := json.Marshal (value)
+	if xb10 != nil {
+		xb05, _ = json.Marshal (fmt.Sprint (value))
+	}
+	return xb05
+}
+
+/* File sink that rotates once it reaches MaxSize.
+ * Rotated files are renamed to <path>.<timestamp>; only the newest MaxBackups (0 - all) younger than MaxAge (0 - any age) are kept
+*/
+type    RotatingFile struct {
+	Path        string
+	MaxSize     int64
+	MaxBackups  int
+	MaxAge      time.Duration
+	Errors      io.Writer  // a failed rotation is reported here once, until a rotation succeeds again (nil - os.Stderr)
+	mutex       sync.Mutex
+	file        *os.File
+	size        int64
+	failing     bool  // the last rotation failed
+}
+
+func    OpenRotatingFile (path string, maxSize int64, maxBackups int, maxAge time.Duration) (*RotatingFile, error) {
+	xb05 := &RotatingFile { Path: path, MaxSize: maxSize, MaxBackups: maxBackups, MaxAge: maxAge }
+	if xb10 := xb05.open (); xb10 != nil {
+		return nil, xb10
+	}
+	return xb05, nil
+}
+
+func (f *RotatingFile) Write (p []byte) (int, error) {
+	f.mutex.Lock ()
+	defer f.mutex.Unlock ()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.MaxSize > 0 && f.size > 0 && f.size + int64 (len (p)) > f.MaxSize {
+		// A file that can't be rotated keeps growing rather than losing the lines
+		xb05 := f.rotate ()
+		if xb05 != nil && f.failing == false {
+			xc05 := f.Errors
+			if xc05 == nil {
+				xc05 = os.Stderr
+			}
+			fmt.Fprintf (xc05, "RotatingFile: %s not rotated, writing on [%s]\n", f.Path, xb05.Error ())
+		}
+		f.failing = xb05 != nil
+	}
+	xb10, xb15 := f.file.Write (p)
+	f.size += int64 (xb10)
+	return xb10, xb15
+}
+
+func (f *RotatingFile) Sync () error {
+	f.mutex.Lock ()
+	defer f.mutex.Unlock ()
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.file.Sync ()
+}
+
+func (f *RotatingFile) Close () error {
+	f.mutex.Lock ()
+	defer f.mutex.Unlock ()
+	if f.file == nil {
+		return nil
+	}
+	xb05 := f.file.Close ()
+	f.file = nil
+	return xb05
+}
+
+func (f *RotatingFile) open () error {
+	xb05, xb10 := os.OpenFile (f.Path, os.O_CREATE | os.O_WRONLY | os.O_APPEND, 0644)
+	if xb10 != nil {
+		return xb10
+	}
+	xb15, xb20 := xb05.Stat ()
+	if xb20 != nil {
+		xb05.Close ()
+		return xb20
+	}
+	f.file, f.size = xb05, xb15.Size ()
+	return nil
+}
+
+func (f *RotatingFile) rotate () error {
+	/***1***/ // The path is reopened even if the rename failed (e.g. the file was removed); the current file is kept if that fails too
+	xb05 := f.Path + "." + time.Now ().UTC ().Format ("20060102T150405.000000000")
+	xb10 := os.Rename (f.Path, xb05)
+	xb12 := f.file
+	if xc05 := f.open (); xc05 != nil {
+		return errors.Join (xb10, xc05)
+	}
+	xb12.Close ()
+	if xb10 != nil {
+		return xb10
+	}
+
+	/***2***/ // Retention; timestamped names sort oldest first
+	xb15, _ := filepath.Glob (f.Path + ".*")
+	slices.Sort (xb15)
+	for xc05, xc10 := range xb15 {
+		xc15 := f.MaxBackups > 0 && xc05 < len (xb15) - f.MaxBackups
+		if xc20, xc25 := os.Stat (xc10); xc25 == nil && f.MaxAge > 0 && time.Since (xc20.ModTime ()) > f.MaxAge {
+			xc15 = true
+		}
+		if xc15 {
+			os.Remove (xc10)
+		}
+	}
+	return nil
+}
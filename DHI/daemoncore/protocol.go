@@ -0,0 +1,167 @@
+package daemoncore
+
+import  "context"
+import  "fmt"
+import  "strconv"
+import  "sync"
+import  "time"
+
+/* Control protocol between Manager and daemon programs.
+ * The manager sends DaemonCommand values over the clap channel; the program reports DaemonMessage values
+ * (StartupResult, ExecutionOutcome, CommandResult, Heartbeat) over the flap channel.
+*/
+type    DaemonProgram func (Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) (error)
+// Program form that also receives a context, cancelled when the manager stops the daemon (see daemonContext)
+type    DaemonContextProgram func (ctx context.Context, Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) (error)
+
+type    CommandKind string
+const (
+	CommandShutdown CommandKind = "shutdown"
+	CommandReload   CommandKind = "reload"
+	CommandStatus   CommandKind = "status"
+)
+type    DaemonCommand struct {
+	Kind  CommandKind
+}
+
+type    DaemonMessage interface {
+	daemonMessage ()
+}
+// Reported once by the program when it is up and running (Code 200) or failed to start
+type    StartupResult struct {
+	Code  int
+	Note  string
+}
+// Reported by DaemonRun once the program has returned or panicked
+type    ExecutionOutcome struct {
+	Code  int
+	Note  string
+}
+// Reported by the program in reply to a reload or status command
+type    CommandResult struct {
+	Command  CommandKind
+	Code     int
+	Note     string
+}
+// Reported by the program every HeartbeatInterval while it is running, see Daemon.HeartbeatTimeout
+type    Heartbeat struct {}
+func (StartupResult) daemonMessage () {}
+func (ExecutionOutcome) daemonMessage () {}
+func (CommandResult) daemonMessage () {}
+func (Heartbeat) daemonMessage () {}
+
+/* Adapts a program written against the map based clap/flap contract ("Command", "StartupCode", "StartupNote") to DaemonProgram.
+ * Shutdown commands are passed on as {"Command": "shutdown"}; reload and status are answered with a 501 CommandResult,
+ * since map based programs treat any clap message as a shutdown.
+*/
+func    MapProgram (Program func (<-chan map[string]string, chan<- map[string]string) (error)) DaemonProgram {
+	return func (Clap <-chan DaemonCommand, Flap chan<- DaemonMessage) (error) {
+		/***1***/
+		xb05 := make (chan map[string]string, 1)
+		xb10 := make (chan map[string]string, 1)
+		xb15 := make (chan struct{})
+		xb20 := make (chan struct{})
+
+		/***2***/ // Commands: typed -> map
+		go func () {
+			for {
+				select {
+				case xd05 := <- Clap:
+					if xd05.Kind != CommandShutdown {
+						Flap <- CommandResult {
+							Command: xd05.Kind, Code: 501,
+							Note: fmt.Sprintf (`Command %s not supported`, xd05.Kind),
+						}
+						continue
+					}
+					select {
+					case xb05 <- map[string]string { "Command": string (xd05.Kind) }:
+					case <- xb15:
+						return
+					}
+				case <- xb15:
+					return
+				}
+			}
+		} ()
+
+		/***3***/ // Reports: map -> typed
+		go func () {
+			defer close (xb20)
+			for xd05 := range xb10 {
+				if _, xd10 := xd05 ["StartupCode"]; xd10 == false {
+					continue
+				}
+				xd15, xd20 := strconv.Atoi (xd05 ["StartupCode"])
+				if xd20 != nil {
+					xd15 = 500
+				}
+				Flap <- StartupResult { Code: xd15, Note: xd05 ["StartupNote"] }
+			}
+		} ()
+
+		/***4***/ // Reports must be passed on before the execution outcome follows
+		defer func () {
+			close (xb15)
+			close (xb10)
+			<- xb20
+		} ()
+		return Program (xb05, xb10)
+	}
+}
+
+/* Context handed to a DaemonContextProgram.
+ * Cancelled when the manager stops the daemon; from then on Deadline reports the end of the daemon's ShutdownGrace
+*/
+type    daemonContext struct {
+	context.Context
+	cancel    context.CancelFunc
+	mutex     sync.Mutex
+	deadline  time.Time
+	abandoned  bool  // the manager gave up on the run (hung); whatever it reports later is dropped
+}
+func    newDaemonContext () *daemonContext {
+	xb05 := &daemonContext {}
+	xb05.Context, xb05.cancel = context.WithCancel (context.Background ())
+	return xb05
+}
+func (c *daemonContext) Deadline () (time.Time, bool) {
+	c.mutex.Lock ()
+	defer c.mutex.Unlock ()
+	return c.deadline, c.deadline.IsZero () == false
+}
+/* Starts the shutdown of the run: sets the deadline (none if grace is 0) and cancels the context
+*/
+func (c *daemonContext) stop (grace time.Duration) {
+	c.mutex.Lock ()
+	if grace != 0 && c.deadline.IsZero () {
+		c.deadline = time.Now ().Add (grace)
+	}
+	c.mutex.Unlock ()
+	c.cancel ()
+}
+
+/* Gives up on the run: cancels the context and marks it abandoned
+*/
+func (c *daemonContext) abandon () {
+	c.mutex.Lock ()
+	c.abandoned = true
+	c.mutex.Unlock ()
+	c.cancel ()
+}
+func (c *daemonContext) isAbandoned () bool {
+	c.mutex.Lock ()
+	defer c.mutex.Unlock ()
+	return c.abandoned
+}
+
+/* Returns a context for the shutdown work of a DaemonContextProgram, once ctx is done.
+ * It is not cancelled along with ctx but expires at the end of the daemon's ShutdownGrace (never, if the daemon has none)
+*/
+func    DaemonShutdownContext (ctx context.Context) (context.Context, context.CancelFunc) {
+	xb05 := context.WithoutCancel (ctx)
+	if xb10, xb15 := ctx.Deadline (); xb15 {
+		return context.WithDeadline (xb05, xb10)
+	}
+	return context.WithCancel (xb05)
+}
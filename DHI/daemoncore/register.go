@@ -0,0 +1,109 @@
+package daemoncore
+
+import  "errors"
+import  "fmt"
+import  "slices"
+
+/* Registers a daemon while the process is running and starts it through the normal startup handshake.
+ * Its dependencies must be registered and running. A daemon without a program is registered but not started.
+ * Returns an error, leaving the daemon unregistered, if it can't be registered or fails to start
+*/
+func (m *Manager) AddDaemon (daemon *Daemon) error {
+	/***1***/
+	if daemon == nil || daemon.Name == "" {
+		return errors.New (`daemon has no name`)
+	}
+	m.register.Lock ()
+	defer m.register.Unlock ()
+	for _ , xc10 := range daemon.DependsOn {
+		xc15 := m.lookup (xc10)
+		if xc15 == nil {
+			return fmt.Errorf (`daemon %s depends on unknown daemon %s`, daemon.Name, xc10)
+		}
+		if xc20 := xc15.Snapshot ().Phase; xc20 != PhaseRunning && xc20 != PhaseDegraded {
+			return fmt.Errorf (`dependency %s is not running`, xc10)
+		}
+	}
+
+	/***2***/ // The register is replaced, never changed in place, so lists handed out by daemons() stay valid
+	m.mutex.Lock ()
+	select {
+	case <- m.shutdown:
+		m.mutex.Unlock ()
+		return errors.New (`shutting down`)
+	default:
+	}
+	if m.stopping {
+		m.mutex.Unlock ()
+		return errors.New (`shutting down`)
+	}
+	if slices.ContainsFunc (m.Daemons, func (d *Daemon) bool { return d.Name == daemon.Name }) {
+		m.mutex.Unlock ()
+		return fmt.Errorf (`daemon %s is already registered`, daemon.Name)
+	}
+	m.Daemons = append (slices.Clip (m.Daemons), daemon)
+	m.mutex.Unlock ()
+	m.logg ("OUT", "Manager", fmt.Sprintf ("Daemon %s registered", daemon.Name))
+
+	/***3***/
+	if daemon.Program == nil && daemon.ContextProgram == nil {
+		return nil
+	}
+	if m.DaemonStart (daemon) == false {
+		m.unregister (daemon)
+		return fmt.Errorf (`daemon %s failed to start [%s]`, daemon.Name, daemon.Snapshot ().LastError)
+	}
+	return nil
+}
+
+/* Stops a daemon, waiting up to its ShutdownGrace, and removes it from the register.
+ * Returns an error if the daemon isn't registered or another registered daemon depends on it;
+ * a daemon that stopped with an error or exceeded its grace period is still removed, and the error returned
+*/
+func (m *Manager) RemoveDaemon (name string) error {
+	/***1***/
+	m.register.Lock ()
+	defer m.register.Unlock ()
+	xb05 := m.lookup (name)
+	if xb05 == nil {
+		return fmt.Errorf (`daemon %s is not registered`, name)
+	}
+	for _ , xc10 := range m.daemons () {
+		if slices.Contains (xc10.DependsOn, name) {
+			return fmt.Errorf (`daemon %s is needed by daemon %s`, name, xc10.Name)
+		}
+	}
+
+	/***2***/
+	xb10 := m.DaemonStop (xb05)
+	m.unregister (xb05)
+	m.logg ("OUT", "Manager", fmt.Sprintf ("Daemon %s removed", name))
+	if xb10.Result == ShutdownError || xb10.Result == ShutdownGraceExceeded {
+		return fmt.Errorf (`daemon %s stopped with an error [%s %s]`, name, xb10.Result, xb10.Note)
+	}
+	return nil
+}
+
+func (m *Manager) unregister (daemon *Daemon) {
+	m.mutex.Lock ()
+	defer m.mutex.Unlock ()
+	m.Daemons = slices.DeleteFunc (slices.Clone (m.Daemons), func (d *Daemon) bool { return d == daemon })
+}
+
+/* Returns the registered daemons, in register order. The list must not be changed
+*/
+func (m *Manager) daemons () []*Daemon {
+	m.mutex.Lock ()
+	defer m.mutex.Unlock ()
+	return m.Daemons
+}
+
+/* Returns the registered daemon with the given name, or nil
+*/
+func (m *Manager) lookup (name string) *Daemon {
+	xb05 := m.daemons ()
+	if xc05 := slices.IndexFunc (xb05, func (daemon *Daemon) bool { return daemon.Name == name }); xc05 >= 0 {
+		return xb05 [xc05]
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package daemoncore
+
+import  "context"
+import  "fmt"
+import  "net"
+import  "os"
+import  "os/signal"
+import  "syscall"
+import  "time"
+
+/* Process exit codes returned by Run, unless changed with WithExitCodes
+*/
+const (
+	ExitClean          = 0
+	ExitStartupFailed  = 1  // configuration, sockets or dependency graph unusable, or a daemon failed to start
+	ExitDaemonFailed   = 2  // a daemon failed while running, or returned an error or exceeded its grace period on shutdown
+)
+
+/* Exit codes Run returns on failure (0 - the default above)
+*/
+type    ExitCodes struct {
+	StartupFailed  int
+	DaemonFailed   int
+}
+
+/* Configures a Manager created by New or Run
+*/
+type    Option func (m *Manager)
+
+// Signals that shut the process down (default SIGINT, SIGTERM)
+func    WithShutdownSignals (signals ...syscall.Signal) Option {
+	return func (m *Manager) { m.ShutdownSignal = signals }
+}
+// Signals that reload the daemons (default SIGHUP)
+func    WithReloadSignals (signals ...syscall.Signal) Option {
+	return func (m *Manager) { m.ReloadSignal = signals }
+}
+// Signal that runs handler and leaves the process running
+func    WithDiagnosticSignal (signal syscall.Signal, handler func ()) Option {
+	return func (m *Manager) { m.DiagnosticSignal [signal] = handler }
+}
+// Logger the manager writes its own lines to (default Logg)
+func    WithLogger (logger *Logger) Option {
+	return func (m *Manager) { m.Logger = logger }
+}
+// Grace periods of daemons that set none
+func    WithGrace (startup, shutdown time.Duration) Option {
+	return func (m *Manager) { m.StartupGrace, m.ShutdownGrace = startup, shutdown }
+}
+// Exit codes Run returns when a daemon fails to start or fails later on
+func    WithExitCodes (startupFailed, daemonFailed int) Option {
+	return func (m *Manager) { m.ExitCodes = ExitCodes { StartupFailed: startupFailed, DaemonFailed: daemonFailed } }
+}
+// Admin control socket, see ServeControl
+func    WithControlSocket (path string) Option {
+	return func (m *Manager) { m.ControlSocket = path }
+}
+// Directory goroutine dumps are written to, see DumpGoroutines
+func    WithDumpDir (dir string) Option {
+	return func (m *Manager) { m.DumpDir = dir }
+}
+// Prepares an Upgrade, see Manager.Handoff
+func    WithHandoff (handoff func () (map[string]net.Listener, error)) Option {
+	return func (m *Manager) { m.Handoff = handoff }
+}
+// JSON-lines file the lifecycle events are appended to, rotated once it exceeds maxSize bytes, see Journal
+func    WithJournal (path string, maxSize int64, maxBackups int) Option {
+	return func (m *Manager) { m.JournalPath, m.JournalMaxSize, m.JournalMaxBackups = path, maxSize, maxBackups }
+}
+// Runs hook once the shutdown begins, before the daemons are stopped
+func    WithShutdownHook (hook func ()) Option {
+	return func (m *Manager) { m.OnShutdown = append (m.OnShutdown, hook) }
+}
+
+/* Creates a manager for the daemons, listening for SIGINT and SIGTERM (shutdown) and SIGHUP (reload) unless the options say otherwise
+*/
+func    New (daemons []*Daemon, options ...Option) *Manager {
+	xb05 := &Manager {
+		Daemons:          daemons,
+		SignalCh:         make (chan os.Signal, 1),
+		ShutdownSignal:   []syscall.Signal { syscall.SIGINT, syscall.SIGTERM },
+		ReloadSignal:     []syscall.Signal { syscall.SIGHUP },
+		DiagnosticSignal: map[syscall.Signal]func () {},
+	}
+	for _ , xc05 := range options {
+		xc05 (xb05)
+	}
+	return xb05
+}
+
+/* Runs the daemons until ctx is done or a shutdown is requested (signal, critical daemon, escalation), then stops them all.
+ * Returns the process exit code, see Manager.Run
+*/
+func    Run (ctx context.Context, daemons []*Daemon, options ...Option) int {
+	return New (daemons, options...).Run (ctx)
+}
+
+/* Starts the daemons in dependency order, supervises them and serves the control socket until ctx is done or a shutdown is requested.
+ * The lifecycle events are appended to JournalPath from before the first daemon starts until the last one stopped.
+ * Every daemon is then stopped, even if some fail, after the OnShutdown hooks have run, and the shutdown report is logged.
+ * Returns ExitClean, or the ExitCodes for a daemon that failed to start (or a dependency graph that can't be started) or failed later on
+*/
+func (m *Manager) Run (ctx context.Context) (code int) {
+	/***1***/
+	if m.SignalCh == nil {
+		m.SignalCh = make (chan os.Signal, 1)
+	}
+	// Registered by Supervise; released once everything is stopped, so a second signal can't kill the process mid-shutdown
+	defer signal.Stop (m.SignalCh)
+	xb05 := m.ExitCodes
+	if xb05.StartupFailed == 0 {
+		xb05.StartupFailed = ExitStartupFailed
+	}
+	if xb05.DaemonFailed == 0 {
+		xb05.DaemonFailed = ExitDaemonFailed
+	}
+	xb10 := false  // a daemon didn't start
+	xb15 := func () {}  // ends the journal
+	if m.JournalPath != "" {
+		xc05, xc10 := OpenRotatingFile (m.JournalPath, m.JournalMaxSize, m.JournalMaxBackups, 0)
+		if xc10 != nil {
+			m.logg ("ERR", "Main", fmt.Sprintf ("PROJECT: Journal not available [%s]", xc10.Error ()))
+		} else {
+			xc15 := m.Journal (xc05)
+			xb15 = func () {
+				xc15 ()
+				xc05.Close ()
+			}
+		}
+	}
+	defer func () {
+		m.logg ("OUT", "Main", "PROJECT: Initiating graceful shutdown")
+		for _ , xc05 := range m.OnShutdown {
+			xc05 ()
+		}
+
+		// Every daemon is stopped, even if some fail
+		xc10 := m.DaemonShutDown ()
+		xc10.Log (m.logger ())
+		switch {
+		case code != ExitClean:
+		case xb10:
+			code = xb05.StartupFailed
+		case xc10.Failed ():
+			code = xb05.DaemonFailed
+		}
+		m.logg ("OUT", "Main", fmt.Sprintf ("PROJECT: Shutdown complete (exit code %d)", code))
+		xb15 ()
+	} ()
+
+	/***2***/
+	if xc05 := m.DaemonStartUp (); xc05 != nil {
+		m.logg ("ERR", "Main", fmt.Sprintf ("PROJECT: Startup aborted [%s]", xc05.Error ()))
+		return xb05.StartupFailed
+	}
+	// Decided by the startup handshakes: a daemon that started and has exited since isn't a startup failure
+	m.mutex.Lock ()
+	xb10 = len (m.notStarted) > 0
+	m.mutex.Unlock ()
+
+	/***3***/
+	go m.Supervise (m.SignalCh)
+	if xc05 := m.ServeControl (); xc05 != nil {
+		m.logg ("ERR", "Main", fmt.Sprintf ("PROJECT: Control socket not available [%s]", xc05.Error ()))
+	}
+
+	// Wait until a shutdown is requested (OS signal, escalated daemon failure, ctx)
+	select {
+	case <- ctx.Done ():
+		m.RequestShutdown (fmt.Sprintf ("Context done [%s]", context.Cause (ctx).Error ()))
+	case <- m.ShutdownRequested ():
+	}
+	return ExitClean
+}
@@ -0,0 +1,214 @@
+package daemoncore
+
+import  "context"
+import  "fmt"
+import  "time"
+
+/* Lifecycle of a daemon as tracked by Manager.
+ * stopped -> starting -> running <-> degraded -> stopping -> stopped; a run that fails to start or exits with an error ends in failed
+*/
+type    DaemonPhase string
+const (
+	PhaseStarting DaemonPhase = "starting"
+	PhaseRunning  DaemonPhase = "running"
+	PhaseDegraded DaemonPhase = "degraded"
+	PhaseStopping DaemonPhase = "stopping"
+	PhaseStopped  DaemonPhase = "stopped"
+	PhaseFailed   DaemonPhase = "failed"
+)
+
+// Interval used for daemons that set a HealthCheck but no HealthInterval
+var     DefaultHealthInterval time.Duration = time.Second * 30
+
+/* Point-in-time copy of a daemon's status, safe to hand to other goroutines
+*/
+type    DaemonSnapshot struct {
+	Name            string       `json:"name"`
+	Phase           DaemonPhase  `json:"phase"`
+	StartedAt       time.Time    `json:"started_at"`
+	Restarts        int          `json:"restarts"`
+	LastError       string       `json:"last_error,omitempty"`
+	HealthCheckedAt time.Time    `json:"health_checked_at"`
+	HealthNote      string       `json:"health_note,omitempty"`  // empty when the last health check passed
+	HeartbeatAt     time.Time    `json:"heartbeat_at"`  // last Heartbeat of the current run
+}
+
+/* Returns a snapshot of every registered daemon, in register order
+*/
+func (m *Manager) Snapshot () []DaemonSnapshot {
+	xb05 := []DaemonSnapshot {}
+	for _ , xc10 := range m.daemons () {
+		xb05 = append (xb05, xc10.Snapshot ())
+	}
+	return xb05
+}
+
+/* Logs one line per registered daemon with its phase, uptime, restarts and last error
+*/
+func (m *Manager) LogState () {
+	for _ , xc05 := range m.Snapshot () {
+		xc10 := time.Duration (0)
+		if xc05.Phase != PhaseStopped && xc05.Phase != PhaseFailed {
+			xc10 = time.Since (xc05.StartedAt).Round (time.Second)
+		}
+		m.logger ().Info ("Manager", "Daemon state",
+			"daemon", xc05.Name, "phase", xc05.Phase, "uptime", xc10, "restarts", xc05.Restarts,
+			"last_error", xc05.LastError, "health", xc05.HealthNote,
+		)
+	}
+}
+
+/* Returns a snapshot of the daemon's status
+*/
+func (daemon *Daemon) Snapshot () DaemonSnapshot {
+	daemon.mutex.Lock ()
+	defer daemon.mutex.Unlock ()
+	xb05 := daemon.status
+	xb05.Name = daemon.Name
+	if xb05.Phase == "" {
+		xb05.Phase = PhaseStopped
+	}
+	return xb05
+}
+
+/* Reports whether the daemon's program is currently executing (starting, running, degraded or stopping)
+*/
+func (daemon *Daemon) Active () bool {
+	switch daemon.Snapshot ().Phase {
+	case PhaseStopped, PhaseFailed:
+		return false
+	}
+	return true
+}
+
+/* Moves the daemon to a new phase. A non-empty note is recorded as the last error
+*/
+func (daemon *Daemon) setPhase (phase DaemonPhase, note string) {
+	daemon.mutex.Lock ()
+	defer daemon.mutex.Unlock ()
+	daemon.status.Phase = phase
+	if phase == PhaseStarting {
+		daemon.status.StartedAt = time.Now ()
+		daemon.status.HealthCheckedAt = time.Time {}
+		daemon.status.HealthNote = ""
+		daemon.status.HeartbeatAt = time.Time {}
+	}
+	if note != "" {
+		daemon.status.LastError = note
+	}
+}
+
+/* Polls the daemon's HealthCheck until the daemon is asked to shut down.
+ * A failing check moves a running daemon to degraded, a passing check moves it back to running
+*/
+func (m *Manager) DaemonHealth (daemon *Daemon) {
+	/***1***/
+	xb05 := daemon.HealthInterval
+	if xb05 == 0 {
+		xb05 = DefaultHealthInterval
+	}
+	xb10 := time.NewTicker (xb05)
+	defer xb10.Stop ()
+
+	for {
+		select {
+		case <- daemon.halt:
+			return
+		case <- xb10.C:
+		}
+		/***2***/ // Only running daemons are checked
+		xc05 := daemon.Snapshot ().Phase
+		if xc05 != PhaseRunning && xc05 != PhaseDegraded {
+			continue
+		}
+		xc10, xc15 := context.WithTimeout (context.Background (), xb05)
+		xc20 := daemon.HealthCheck (xc10)
+		xc15 ()
+
+		/***3***/
+		daemon.mutex.Lock ()
+		daemon.status.HealthCheckedAt = time.Now ()
+		daemon.status.HealthNote = ""
+		xc25 := daemon.status.Phase
+		if xc20 != nil {
+			daemon.status.HealthNote = xc20.Error ()
+			if xc25 == PhaseRunning {
+				daemon.status.Phase = PhaseDegraded
+			}
+		} else if xc25 == PhaseDegraded {
+			daemon.status.Phase = PhaseRunning
+		}
+		xc30 := daemon.status.Phase
+		daemon.mutex.Unlock ()
+
+		if xc30 == PhaseDegraded && xc25 == PhaseRunning {
+			m.logg ("ERR", "Main", fmt.Sprintf (`PROJECT: Daemon %s: Health check failed [%s]`, daemon.Name, xc20.Error ()))
+			m.publish (EventHealthChanged, daemon.Name, "phase", xc30, "note", xc20.Error ())
+		}
+		if xc30 == PhaseRunning && xc25 == PhaseDegraded {
+			m.logg ("OUT", "Main", fmt.Sprintf (`PROJECT: Daemon %s: Health check passed`, daemon.Name))
+			m.publish (EventHealthChanged, daemon.Name, "phase", xc30)
+		}
+	}
+}
+
+/* How a daemon stopped, see DaemonStop
+*/
+type    ShutdownResult string
+const (
+	ShutdownClean          ShutdownResult = "clean"
+	ShutdownError          ShutdownResult = "error"           // the program returned an error or panicked
+	ShutdownGraceExceeded  ShutdownResult = "grace exceeded"  // the program didn't return within ShutdownGrace
+	ShutdownFailed         ShutdownResult = "failed"          // not running: failed to start, or its last run failed
+	ShutdownNotRunning     ShutdownResult = "not running"
+)
+type    DaemonShutdown struct {
+	Name    string
+	Result  ShutdownResult
+	Note    string
+	Took    time.Duration
+}
+/* Outcomes of DaemonShutDown, in shutdown order
+*/
+type    ShutdownReport struct {
+	Daemons  []DaemonShutdown
+}
+
+/* Reports whether the daemon failed: it returned an error, exceeded its grace period or wasn't running because it failed
+*/
+func (d DaemonShutdown) Failed () bool {
+	return d.Result == ShutdownError || d.Result == ShutdownGraceExceeded || d.Result == ShutdownFailed
+}
+
+/* Reports whether any daemon failed
+*/
+func (r ShutdownReport) Failed () bool {
+	for _ , xc10 := range r.Daemons {
+		if xc10.Failed () {
+			return true
+		}
+	}
+	return false
+}
+
+/* Logs one line per daemon, failures at error level
+*/
+func (r ShutdownReport) Log (logger *Logger) {
+	xb05 := 0
+	for _ , xc10 := range r.Daemons {
+		xc15 := []any { "daemon", xc10.Name, "result", string (xc10.Result) }
+		if xc10.Result != ShutdownNotRunning && xc10.Result != ShutdownFailed {
+			xc15 = append (xc15, "took", xc10.Took.Round (time.Millisecond))
+		}
+		if xc10.Note != "" {
+			xc15 = append (xc15, "note", xc10.Note)
+		}
+		if xc10.Failed () {
+			xb05++
+			logger.Error ("Main", "PROJECT: Shutdown report", xc15...)
+		} else {
+			logger.Info ("Main", "PROJECT: Shutdown report", xc15...)
+		}
+	}
+	logger.Info ("Main", "PROJECT: Shutdown report", "daemons", len (r.Daemons), "failed", xb05)
+}
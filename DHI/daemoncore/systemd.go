@@ -0,0 +1,174 @@
+package daemoncore
+
+import  "fmt"
+import  "net"
+import  "os"
+import  "strconv"
+import  "strings"
+import  "syscall"
+import  "time"
+
+/* systemd service notification (sd_notify).
+ * Without NOTIFY_SOCKET in the environment (not started by systemd, or Type is not notify) every call is a no-op
+*/
+func    SystemdNotify (state string) error {
+	return notifySocket (os.Getenv ("NOTIFY_SOCKET"), state)
+}
+func    notifySocket (path, state string) error {
+	xb05 := path
+	if xb05 == "" {
+		return nil
+	}
+	if strings.HasPrefix (xb05, "@") {
+		xb05 = "\x00" + xb05 [1:]  // abstract socket
+	}
+	xb10, xb15 := net.DialUnix ("unixgram", nil, &net.UnixAddr { Name: xb05, Net: "unixgram" })
+	if xb15 != nil {
+		return xb15
+	}
+	defer xb10.Close ()
+	_, xb15 = xb10.Write ([]byte (state))
+	return xb15
+}
+
+/* Returns the watchdog interval systemd expects pings within (WatchdogSec), or 0 if the watchdog isn't enabled for this process
+*/
+func    SystemdWatchdogInterval () time.Duration {
+	xb05, xb10 := strconv.ParseInt (os.Getenv ("WATCHDOG_USEC"), 10, 64)
+	if xb10 != nil || xb05 <= 0 {
+		return 0
+	}
+	if xb15 := os.Getenv ("WATCHDOG_PID"); xb15 != "" && xb15 != strconv.Itoa (os.Getpid ()) {
+		return 0
+	}
+	return time.Duration (xb05) * time.Microsecond
+}
+
+/* Reports whether no daemon is starting, degraded or failed. Daemons stopped on purpose don't count,
+ * nor do failed daemons that aren't critical and won't be restarted (RestartNever, restart budget used up)
+*/
+func (m *Manager) Healthy () bool {
+	for _ , xc05 := range m.daemons () {
+		switch xc05.Snapshot ().Phase {
+		case PhaseStarting, PhaseDegraded:
+			return false
+		case PhaseFailed:
+			xc05.mutex.Lock ()
+			xc10 := xc05.restarting
+			xc05.mutex.Unlock ()
+			if xc05.Critical || xc10 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+/* Tells systemd the startup is complete (READY=1) if every daemon with a program reported a successful startup,
+ * then keeps the watchdog fed while the daemons are healthy
+*/
+func (m *Manager) systemdReady () {
+	/***1***/
+	for _ , xc10 := range m.daemons () {
+		if xc10.Program == nil && xc10.ContextProgram == nil {
+			continue
+		}
+		if xc15 := xc10.Snapshot (); xc15.Phase != PhaseRunning {
+			SystemdNotify (fmt.Sprintf ("STATUS=Daemon %s is %s", xc10.Name, xc15.Phase))
+			m.upgradeNotify (fmt.Sprintf ("STATUS=Daemon %s is %s", xc10.Name, xc15.Phase))
+			return
+		}
+	}
+	if xb05 := SystemdNotify ("READY=1\nSTATUS=Up and running"); xb05 != nil {
+		m.logg ("ERR", "Manager", fmt.Sprintf ("systemd notification failed [%s]", xb05.Error ()))
+	}
+	m.upgradeNotify ("READY=1")
+
+	/***2***/ // Pings at half the watchdog interval, skipped while a daemon is unhealthy
+	xb10 := SystemdWatchdogInterval ()
+	if xb10 == 0 {
+		return
+	}
+	go func () {
+		xc05 := time.NewTicker (xb10 / 2)
+		defer xc05.Stop ()
+		for {
+			select {
+			case <- m.ShutdownRequested ():
+				return
+			case <- xc05.C:
+			}
+			m.mutex.Lock ()
+			xc10 := m.stopping
+			m.mutex.Unlock ()
+			if xc10 {
+				return
+			}
+			if m.Healthy () {
+				SystemdNotify ("WATCHDOG=1")
+			}
+		}
+	} ()
+}
+
+/* Tells systemd the shutdown has begun (STOPPING=1) and ends the watchdog pings
+*/
+func (m *Manager) systemdStopping () {
+	m.mutex.Lock ()
+	xb05 := m.stopping
+	m.stopping = true
+	m.mutex.Unlock ()
+	if xb05 == false {
+		SystemdNotify ("STOPPING=1")
+	}
+}
+
+/* Returns the listeners passed by systemd socket activation (LISTEN_FDS), by name (FileDescriptorName=, "unknown" if unnamed).
+ * The LISTEN_* variables are removed from the environment so child processes don't take the descriptors for theirs.
+ * Returns an error if a descriptor is not a listening socket or two share a name
+*/
+func    SystemdListeners () (map[string]net.Listener, error) {
+	return systemdListenFDs (3)
+}
+func    systemdListenFDs (first int) (map[string]net.Listener, error) {
+	xb05, xb10 := strconv.Atoi (os.Getenv ("LISTEN_FDS"))
+	if xb10 != nil || xb05 <= 0 || os.Getenv ("LISTEN_PID") != strconv.Itoa (os.Getpid ()) {
+		return map[string]net.Listener {}, nil
+	}
+	xb15 := strings.Split (os.Getenv ("LISTEN_FDNAMES"), ":")
+	defer func () {
+		os.Unsetenv ("LISTEN_PID")
+		os.Unsetenv ("LISTEN_FDS")
+		os.Unsetenv ("LISTEN_FDNAMES")
+	} ()
+	xb20 := []string {}
+	for xc05 := 0; xc05 < xb05; xc05++ {
+		if xc05 < len (xb15) && xb15 [xc05] != "" {
+			xb20 = append (xb20, xb15 [xc05])
+		} else {
+			xb20 = append (xb20, "unknown")
+		}
+	}
+	return listenFDs (first, xb20)
+}
+
+/* Turns the inherited descriptors first, first+1, ... into listeners, by name
+*/
+func    listenFDs (first int, names []string) (map[string]net.Listener, error) {
+	xb05 := map[string]net.Listener {}
+	for xc05, xc10 := range names {
+		syscall.CloseOnExec (first + xc05)
+		xc15 := os.NewFile (uintptr (first + xc05), xc10)
+		xc20, xc25 := net.FileListener (xc15)
+		xc15.Close ()
+		if xc25 != nil {
+			return nil, fmt.Errorf (`descriptor %d (%s) is not a listening socket [%s]`, first + xc05, xc10, xc25.Error ())
+		}
+		if _, xc30 := xb05 [xc10]; xc30 {
+			xc20.Close ()
+			return nil, fmt.Errorf (`more than one descriptor named %s`, xc10)
+		}
+		xb05 [xc10] = xc20
+	}
+	return xb05, nil
+}
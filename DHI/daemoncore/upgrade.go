@@ -0,0 +1,169 @@
+package daemoncore
+
+import  "errors"
+import  "fmt"
+import  "maps"
+import  "net"
+import  "os"
+import  "os/exec"
+import  "path/filepath"
+import  "slices"
+import  "strings"
+import  "syscall"
+import  "time"
+
+/* Zero-downtime upgrade.
+ * The running process starts the executable at its own path again (the newly deployed binary), passing its listening sockets
+ * as descriptors 3, 4, ... The new process serves on them alongside the old one, reports READY=1 once its daemons are up,
+ * and the old process then shuts down, draining its in-flight requests.
+*/
+const (
+	upgradeFDNamesEnv = "LYTUP_UPGRADE_FDNAMES"  // names of the passed listeners, in descriptor order, separated by ":"
+	upgradeNotifyEnv  = "LYTUP_UPGRADE_NOTIFY"   // unixgram socket the new process reports its startup on
+)
+
+/* Returns the listeners handed over by the process that started this one with Upgrade, by name (none if not started that way)
+*/
+func    InheritedListeners () (map[string]net.Listener, error) {
+	xb05 := os.Getenv (upgradeFDNamesEnv)
+	if xb05 == "" {
+		return map[string]net.Listener {}, nil
+	}
+	os.Unsetenv (upgradeFDNamesEnv)
+	return listenFDs (3, strings.Split (xb05, ":"))
+}
+
+/* Reports the startup to the process that started this one with Upgrade. Only the first report is sent
+*/
+func (m *Manager) upgradeNotify (state string) {
+	xb05 := os.Getenv (upgradeNotifyEnv)
+	if xb05 == "" {
+		return
+	}
+	os.Unsetenv (upgradeNotifyEnv)
+	if xb10 := notifySocket (xb05, state); xb10 != nil {
+		m.logg ("ERR", "Manager", fmt.Sprintf ("Upgrade notification failed [%s]", xb10.Error ()))
+	}
+}
+
+/* Replaces this process with a new one started from the same executable path and arguments, without closing the listening sockets.
+ * m.Handoff saves the state to carry over and returns the listeners to pass on. The control socket is released for the new process.
+ * On success this process is asked to shut down (see ShutdownRequested); systemd is told the new main PID.
+ * Returns an error, leaving this process running, if the new process fails to start or doesn't report ready within UpgradeTimeout
+*/
+func (m *Manager) Upgrade () error {
+	/***1***/
+	if m.Handoff == nil {
+		return errors.New (`upgrade not supported (no Handoff)`)
+	}
+	if m.upgrading.TryLock () == false {
+		return errors.New (`an upgrade is already in progress`)
+	}
+	defer m.upgrading.Unlock ()
+	xb05, xb10 := m.Handoff ()
+	if xb10 != nil {
+		return fmt.Errorf (`handoff failed [%s]`, xb10.Error ())
+	}
+	xb15 := []string {}
+	xb20 := []*os.File {}
+	defer func () {
+		for _ , xc10 := range xb20 { xc10.Close () }
+	} ()
+	// Descriptors are passed in name order
+	for _ , xc05 := range slices.Sorted (maps.Keys (xb05)) {
+		xc15, xc20 := xb05 [xc05].(interface { File () (*os.File, error) })
+		if xc20 == false {
+			return fmt.Errorf (`listener %s can't be passed on`, xc05)
+		}
+		xc25, xc30 := xc15.File ()
+		if xc30 != nil {
+			return fmt.Errorf (`listener %s can't be passed on [%s]`, xc05, xc30.Error ())
+		}
+		xb15 = append (xb15, xc05)
+		xb20 = append (xb20, xc25)
+	}
+
+	/***2***/ // The new process reports its startup here
+	xb25, xb30 := os.MkdirTemp ("", "lytup-upgrade")
+	if xb30 != nil {
+		return xb30
+	}
+	defer os.RemoveAll (xb25)
+	xb35, xb40 := net.ListenUnixgram ("unixgram", &net.UnixAddr { Name: filepath.Join (xb25, "notify.sock"), Net: "unixgram" })
+	if xb40 != nil {
+		return xb40
+	}
+	defer xb35.Close ()
+
+	/***3***/
+	xb45, xb50 := os.Executable ()
+	if xb50 != nil {
+		return xb50
+	}
+	m.closeControl ()
+	xb55 := exec.Command (xb45, os.Args [1:]...)
+	xb55.Stdout, xb55.Stderr = os.Stdout, os.Stderr
+	xb55.ExtraFiles = xb20
+	// WATCHDOG_PID names this process; without it the new process feeds the watchdog itself
+	xb55.Env = append (
+		slices.DeleteFunc (os.Environ (), func (env string) bool { return strings.HasPrefix (env, "WATCHDOG_PID=") }),
+		upgradeFDNamesEnv + "=" + strings.Join (xb15, ":"),
+		upgradeNotifyEnv + "=" + xb35.LocalAddr ().String (),
+	)
+	m.logg ("OUT", "Manager", fmt.Sprintf ("Upgrade: starting %s with listener(s) %s", xb45, strings.Join (xb15, ", ")))
+	xb60 := xb55.Start ()
+	// Passing the descriptors switched the sockets, shared with this process' listeners, to blocking mode
+	for _ , xc10 := range xb20 {
+		if xc15, xc20 := xc10.SyscallConn (); xc20 == nil {
+			xc15.Control (func (fd uintptr) { syscall.SetNonblock (int (fd), true) })
+		}
+	}
+	if xb60 != nil {
+		m.ServeControl ()
+		return fmt.Errorf (`new process not started [%s]`, xb60.Error ())
+	}
+
+	/***4***/ // Wait for the new process to report
+	xb65 := make (chan error, 1)
+	go func () { xb65 <- xb55.Wait () } ()
+	xb70 := make (chan string, 1)
+	go func () {
+		xc05 := make ([]byte, 4096)
+		if xc10, xc15 := xb35.Read (xc05); xc15 == nil {
+			xb70 <- string (xc05 [:xc10])
+		}
+	} ()
+	var xb75 error
+	select {
+	case xc05 := <- xb70:
+		if strings.Contains (xc05, "READY=1") == false {
+			xb75 = fmt.Errorf (`new process failed to start [%s]`, strings.TrimPrefix (xc05, "STATUS="))
+		}
+	case xc05 := <- xb65:
+		xb75 = fmt.Errorf (`new process exited before it was ready [%v]`, xc05)
+	case <- time.After (UpgradeTimeout):
+		xb75 = fmt.Errorf (`new process not ready within %v`, UpgradeTimeout)
+	}
+	if xb75 != nil {
+		xb55.Process.Kill ()
+		m.ServeControl ()
+		return xb75
+	}
+
+	/***5***/ // The new process takes over notifying systemd; this one no longer reports STOPPING=1 or feeds the watchdog
+	m.mutex.Lock ()
+	m.stopping = true
+	m.upgraded = true
+	m.mutex.Unlock ()
+	SystemdNotify (fmt.Sprintf ("MAINPID=%d", xb55.Process.Pid))
+	m.RequestShutdown (fmt.Sprintf ("Upgraded to process %d", xb55.Process.Pid))
+	return nil
+}
+
+/* Returns true once Upgrade has handed over to a new process: state saved by Handoff must not be overwritten while this one shuts down
+*/
+func (m *Manager) Upgraded () bool {
+	m.mutex.Lock ()
+	defer m.mutex.Unlock ()
+	return m.upgraded
+}
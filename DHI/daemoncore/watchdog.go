@@ -0,0 +1,106 @@
+package daemoncore
+
+import  "fmt"
+import  "os"
+import  "path/filepath"
+import  "regexp"
+import  "runtime/pprof"
+import  "time"
+
+/* What the manager does with a daemon that missed its heartbeat deadline or overran its ShutdownGrace, once the goroutines are dumped.
+ * A hung run can't be killed: it is abandoned (its context is cancelled and whatever it reports later is dropped)
+*/
+type    HungPolicy string
+const (
+	HungRestart   HungPolicy = "restart"    // a new run of the daemon is started (default); not applied to an overrun ShutdownGrace
+	HungTerminate HungPolicy = "terminate"  // the process is shut down
+)
+
+/* Watches the heartbeats of a running daemon until it is asked to shut down.
+ * Once no heartbeat arrived for HeartbeatTimeout (counted from the start of the run) the daemon is handled as hung
+*/
+func (m *Manager) DaemonHeartbeat (daemon *Daemon) {
+	/***1***/
+	daemon.mutex.Lock ()
+	xb05 := daemon.halt
+	daemon.mutex.Unlock ()
+	xb10 := time.NewTicker (max (daemon.HeartbeatTimeout / 4, time.Millisecond))
+	defer xb10.Stop ()
+
+	for {
+		select {
+		case <- xb05:
+			return
+		case <- xb10.C:
+		}
+		/***2***/ // Only running daemons are expected to beat
+		xc05 := daemon.Snapshot ()
+		if xc05.Phase != PhaseRunning && xc05.Phase != PhaseDegraded {
+			continue
+		}
+		xc10 := xc05.StartedAt
+		if xc05.HeartbeatAt.After (xc10) {
+			xc10 = xc05.HeartbeatAt
+		}
+		if time.Since (xc10) <= daemon.HeartbeatTimeout {
+			continue
+		}
+
+		/***3***/
+		m.daemonHung (daemon, fmt.Sprintf (`No heartbeat for %v`, time.Since (xc10).Round (time.Millisecond)))
+	}
+}
+
+/* Dumps the goroutines, abandons the daemon's current run and hands it to DaemonWatch, which applies the HungPolicy.
+ * Returns the note recorded as the daemon's last error
+*/
+func (m *Manager) daemonHung (daemon *Daemon, reason string) string {
+	xb05, xb10 := m.DumpGoroutines (daemon.Name)
+	if xb10 != nil {
+		reason = fmt.Sprintf (`%s, goroutines not dumped [%s]`, reason, xb10.Error ())
+	} else {
+		reason = fmt.Sprintf (`%s, goroutines dumped to %s`, reason, xb05)
+	}
+	m.logg ("ERR", "Main", fmt.Sprintf (`PROJECT: Daemon %s: Hung [%s]`, daemon.Name, reason))
+
+	daemon.mutex.Lock ()
+	xb15 := daemon.context
+	xb20 := daemon.hung
+	daemon.mutex.Unlock ()
+	xb15.abandon ()
+	daemon.setPhase (PhaseFailed, reason)
+	// A signal DaemonWatch didn't take yet is for an older run
+	for {
+		select {
+		case xb20 <- xb15:
+			return reason
+		default:
+		}
+		select {
+		case <- xb20:
+		default:
+		}
+	}
+}
+
+/* Writes the stacks of all goroutines to a file in DumpDir, named after label and the time.
+ * Returns the path of the file
+*/
+func (m *Manager) DumpGoroutines (label string) (string, error) {
+	xb05 := m.DumpDir
+	if xb05 == "" {
+		xb05 = "."
+	}
+	xb10 := filepath.Join (xb05, fmt.Sprintf (
+		`goroutines-%s-%s.txt`, regexp.MustCompile (`[^A-Za-z0-9_.-]`).ReplaceAllString (label, "_"), time.Now ().Format ("20060102-150405.000"),
+	))
+	xb15, xb20 := os.Create (xb10)
+	if xb20 != nil {
+		return "", xb20
+	}
+	defer xb15.Close ()
+	if xb25 := pprof.Lookup ("goroutine").WriteTo (xb15, 2); xb25 != nil {
+		return "", xb25
+	}
+	return xb10, nil
+}
@@ -1,3 +1,3 @@
-module main
+module github.com/PreciousM01/Precious-Lytup-Backend/DHI
 
 go 1.24.10
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"time"
+
+	"github.com/PreciousM01/Precious-Lytup-Backend/DHI/daemoncore"
 )
 
 type WeatherAPIResponse struct {
@@ -37,19 +42,34 @@ type GeocodingResponse struct {
 	} `json:"results"`
 }
 
+// Geocoding API searched by geocodeCity
+var geocodingEndpoint = "https://geocoding-api.open-meteo.com/v1/search"
+
+// Returned by geocodeCity when the geocoding service knows no such city
+var errCityNotFound = errors.New("city not found")
+
 // Helper function to geocode city name to coordinates
-func geocodeCity(city string) (lat, lon float64, err error) {
+// The request is abandoned when ctx is cancelled (client gone or DHI shutdown deadline reached)
+func geocodeCity(ctx context.Context, city string) (lat, lon float64, err error) {
 	geocodeURL := fmt.Sprintf(
-		"https://geocoding-api.open-meteo.com/v1/search?name=%s&count=1&language=en&format=json",
-		url.QueryEscape(city),
+		"%s?name=%s&count=1&language=en&format=json",
+		geocodingEndpoint, url.QueryEscape(city),
 	)
 
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, geocodeURL, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("geocoding request failed: %w", err)
+	}
+
 	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Get(geocodeURL)
+	resp, err := client.Do(req)
 	if err != nil {
 		return 0, 0, fmt.Errorf("geocoding request failed: %w", err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, 0, fmt.Errorf("geocoding request failed: %s", resp.Status)
+	}
 
 	var geoResp GeocodingResponse
 	if err := json.NewDecoder(resp.Body).Decode(&geoResp); err != nil {
@@ -57,7 +77,7 @@ func geocodeCity(city string) (lat, lon float64, err error) {
 	}
 
 	if len(geoResp.Results) == 0 {
-		return 0, 0, fmt.Errorf("city not found: %s", city)
+		return 0, 0, fmt.Errorf("%w: %s", errCityNotFound, city)
 	}
 
 	return geoResp.Results[0].Latitude, geoResp.Results[0].Longitude, nil
@@ -70,21 +90,10 @@ func SPWeatherForecast(
 	seed map[string]any,
 ) (C int, N string, Y any) {
 
-	// 1. Extract inputs
-	city, ok := seed["city"].(string)
-	if !ok || city == "" {
-		return 400, "missing city", nil
-	}
-
-	startDate, ok := seed["start_date"].(string)
-	if !ok || startDate == "" {
-		return 400, "missing start_date", nil
-	}
-
-	endDate, ok := seed["end_date"].(string)
-	if !ok || endDate == "" {
-		return 400, "missing end_date", nil
-	}
+	// 1. Extract inputs, validated by the schema in DHI0_SPRegister
+	city, _ := seed["city"].(string)
+	startDate, _ := seed["start_date"].(string)
+	endDate, _ := seed["end_date"].(string)
 
 	// Get data type (defaults to "both")
 	dataType, _ := seed["data_type"].(string)
@@ -92,33 +101,65 @@ func SPWeatherForecast(
 		dataType = "both"
 	}
 
-	// 2. Determine cache TTL based on data type
-	var cacheTTL time.Duration
+	// 2. Check cache
+	cacheKey := GlobalWeatherCache.GenerateKey(city+dataType, startDate, endDate)
+	if cachedData, found := GlobalWeatherCache.Get(cacheKey); found {
+		daemoncore.Logg.Debug("Weather", "Cache hit", "city", city, "data_type", dataType)
+		return 200, "Weather data retrieved from cache", cachedData
+	}
+
+	daemoncore.Logg.Debug("Weather", "Cache miss, fetching from API", "city", city, "data_type", dataType)
+
+	// 3. Fetch and store in cache with dynamic TTL
+	source := &CacheSource{City: city, DataType: dataType, StartDate: startDate, EndDate: endDate}
+	C, N, responseData := fetchWeather(r.Context(), *source)
+	switch C {
+	case 200:
+	case 400:
+		return DHI0_Fail(C, N, DHI0_FieldError("city", "geocoding_failed", N))
+	case 502, 504:
+		return DHI0_RetryLater(C, N, 30*time.Second)
+	default:
+		return C, N, nil
+	}
+	cacheTTL := weatherCacheTTL(dataType)
+	GlobalWeatherCache.SetWithSource(cacheKey, responseData, cacheTTL, source)
+	daemoncore.Logg.Debug("Weather", "Cached", "city", city, "data_type", dataType, "ttl", cacheTTL)
+
+	return 200, "Weather data retrieved successfully", responseData
+}
+
+// Cache TTL based on data type
+func weatherCacheTTL(dataType string) time.Duration {
 	switch dataType {
 	case "current":
-		cacheTTL = 30 * time.Minute
+		return 30 * time.Minute
 	case "hourly":
-		cacheTTL = 1 * time.Hour
+		return 1 * time.Hour
 	default:
-		cacheTTL = 30 * time.Minute
-	}
-
-	// 3. Check cache
-	cacheKey := GlobalWeatherCache.GenerateKey(city+dataType, startDate, endDate)
-	if cachedData, found := GlobalWeatherCache.Get(cacheKey); found {
-		Output_Logg("OUT", "Weather", fmt.Sprintf("Cache HIT for %s (%s data)", city, dataType))
-		return 200, "Weather data retrieved from cache", cachedData
+		return 30 * time.Minute
 	}
+}
 
-	Output_Logg("OUT", "Weather", fmt.Sprintf("Cache MISS for %s - fetching from API", city))
+// Fetches the forecast for a request from the API, returning the SP response code, note and data
+func fetchWeather(ctx context.Context, source CacheSource) (int, string, map[string]any) {
+	city, dataType, startDate, endDate := source.City, source.DataType, source.StartDate, source.EndDate
 
-	// 4. Geocode
-	latitude, longitude, err := geocodeCity(city)
-	if err != nil {
+	// 1. Geocode; only an unknown city is the caller's fault
+	latitude, longitude, err := geocodeCity(ctx, city)
+	var netErr net.Error
+	switch {
+	case err == nil:
+	case errors.Is(err, errCityNotFound):
 		return 400, fmt.Sprintf("failed to geocode city: %s", err.Error()), nil
+	case errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout():
+		return 504, "geocoding service did not answer in time", nil
+	default:
+		daemoncore.Logg.Warn("Weather", "Geocoding failed", "city", city, "error", err.Error())
+		return 502, "failed to contact geocoding service", nil
 	}
 
-	// 5. Build API URL
+	// 2. Build API URL
 	weatherURL := fmt.Sprintf(
 		"https://api.open-meteo.com/v1/forecast"+
 			"?latitude=%f"+
@@ -131,9 +172,14 @@ func SPWeatherForecast(
 		latitude, longitude, startDate, endDate,
 	)
 
-	// 6. Fetch from API
+	// 3. Fetch from API
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, weatherURL, nil)
+	if err != nil {
+		return 500, "failed to build weather request", nil
+	}
+
 	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(weatherURL)
+	resp, err := client.Do(req)
 	if err != nil {
 		return 502, "failed to contact weather service", nil
 	}
@@ -144,7 +190,7 @@ func SPWeatherForecast(
 		return 500, "failed to parse weather response", nil
 	}
 
-	// 7. Build response based on data type
+	// 4. Build response based on data type
 	responseData := map[string]any{
 		"city":     city,
 		"location": map[string]float64{"latitude": latitude, "longitude": longitude},
@@ -160,9 +206,14 @@ func SPWeatherForecast(
 		responseData["hourly"] = apiResp.Hourly
 	}
 
-	// 8. Store in cache with dynamic TTL
-	GlobalWeatherCache.SetWithTTL(cacheKey, responseData, cacheTTL)
-	Output_Logg("OUT", "Weather", fmt.Sprintf("Cached %s data for %s (TTL: %v)", dataType, city, cacheTTL))
-
 	return 200, "Weather data retrieved successfully", responseData
-}
\ No newline at end of file
+}
+
+// Refreshes a cache entry for the warming job (see WeatherCache.Warm)
+func refreshWeather(ctx context.Context, source CacheSource) (any, time.Duration, error) {
+	code, note, data := fetchWeather(ctx, source)
+	if code != 200 {
+		return nil, 0, fmt.Errorf("%d %s", code, note)
+	}
+	return data, weatherCacheTTL(source.DataType), nil
+}
@@ -2,10 +2,12 @@ package main
 
 import  "syscall"
 
-var     DaemonRegister []*Daemon = []*Daemon { }
+import  "github.com/PreciousM01/Precious-Lytup-Backend/DHI/daemoncore"
+
+var     DaemonRegister []*daemoncore.Daemon = []*daemoncore.Daemon { }
 var     SupportedShutdownSignal []syscall.Signal = []syscall.Signal {
 	syscall.SIGHUP ,
 	syscall.SIGINT ,
 	syscall.SIGTERM,
 }
-var     TimeZoneSecondOffset int = 0
+var     TimeZone string = "UTC"  // IANA name used for log timestamps
@@ -2,18 +2,20 @@ package main
 
 import "time"
 
+import  "github.com/PreciousM01/Precious-Lytup-Backend/DHI/daemoncore"
+
 func    init () {
-	DaemonRegister = []*Daemon {
-		&Daemon {
-			Name: "Daemon 01", Program: Daemon01, StartupGrace: time.Second*5,
+	DaemonRegister = []*daemoncore.Daemon {
+		&daemoncore.Daemon {
+			Name: "Daemon 01", Program: daemoncore.MapProgram (Daemon01), StartupGrace: time.Second*5,
 			ShutdownGrace:time.Second*5,
 		},
-		&Daemon {
-			Name: "Daemon 02", Program: Daemon02, StartupGrace: time.Second*5,
+		&daemoncore.Daemon {
+			Name: "Daemon 02", Program: daemoncore.MapProgram (Daemon02), StartupGrace: time.Second*5,
 			ShutdownGrace:time.Second*5,
 		},
 	}
-	TimeZoneSecondOffset = 2*60*60
+	TimeZone = "Etc/GMT-2"
 }
 func    Daemon01 (Clap <-chan map[string]string, Flap chan <- map[string]string) (E error) {
 	xb05 := map[string]string{ }
@@ -1,3 +1,7 @@
-module main
+module github.com/PreciousM01/Precious-Lytup-Backend/daemon
 
 go 1.24.10
+
+require github.com/PreciousM01/Precious-Lytup-Backend/DHI v0.0.0
+
+replace github.com/PreciousM01/Precious-Lytup-Backend/DHI => ../DHI